@@ -1,13 +1,37 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
 
 	"github.com/integralist/terraform-provider-mock/mock"
 )
 
 func main() {
+	// `terraform-provider-mock serve-api` runs the mock API server instead of
+	// the provider (see mock/api_server.go). Terraform always runs the
+	// provider without any arguments.
+	if len(os.Args) > 1 && os.Args[1] == "serve-api" {
+		if err := mock.ServeAPI(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// `terraform-provider-mock openapi` prints the mock API server's OpenAPI
+	// document (see mock/api_openapi.go).
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		if err := mock.WriteOpenAPI(os.Stdout, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: mock.Provider,
+		// Rather than ProviderFunc we give the SDK our own gRPC server, which
+		// wraps the SDK's so that 'strict_consistency' can work.
+		GRPCProviderFunc: mock.GRPCProviderServer,
 	})
 }
@@ -0,0 +1,137 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// The mock API server (see api_server.go) and the client the provider uses to
+// talk to it (see api_client.go) share the types in this file, which describe
+// what goes over the wire.
+//
+// Objects are sent as the same JSON the backend persists them as. The types
+// below are only needed for the calls that send part of an object, and for
+// errors.
+
+// idempotencyKeyHeader carries the key identifying a request that may be
+// retried (see api_client.go).
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader is set on a response that was recorded for an
+// earlier request with the same idempotency key.
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// apiError is the body of every error response, wrapped in an
+// apiErrorResponse. Code is one of the codes in backendErrorCodes (so the
+// client can turn it back into an error that errors.Is understands) or one of
+// the server's own codes below.
+type apiError struct {
+	// Status is the HTTP status the error was (or will be) sent with.
+	Status int `json:"-"`
+
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Kind, Name and ID describe the object that's already using a name for
+	// an "already_exists" error.
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id,omitempty"`
+}
+
+type apiErrorResponse struct {
+	Error *apiError `json:"error"`
+}
+
+// The codes of errors that come from the server itself rather than the
+// backend.
+//
+// NOTE: An unknown path has its own code rather than "not_found". Otherwise a
+// wrong 'api_url' would look like every object had been deleted, and READ
+// would quietly remove them all from state.
+const (
+	apiErrorBadRequest       = "bad_request"
+	apiErrorUnauthorized     = "unauthorized"
+	apiErrorForbidden        = "forbidden"
+	apiErrorNoSuchPath       = "no_such_path"
+	apiErrorMethodNotAllowed = "method_not_allowed"
+	apiErrorIdempotencyKey   = "idempotency_key_reused"
+	apiErrorRateLimited      = "rate_limited"
+	apiErrorUnavailable      = "unavailable"
+	apiErrorGatewayTimeout   = "gateway_timeout"
+	apiErrorInternal         = "internal"
+)
+
+// apiErrorStatus is the HTTP status for each error code.
+var apiErrorStatus = map[string]int{
+	"not_found":      http.StatusNotFound,
+	"conflict":       http.StatusConflict,
+	"already_exists": http.StatusConflict,
+	"invalid_parent": http.StatusUnprocessableEntity,
+
+	apiErrorBadRequest:       http.StatusBadRequest,
+	apiErrorUnauthorized:     http.StatusUnauthorized,
+	apiErrorForbidden:        http.StatusForbidden,
+	apiErrorNoSuchPath:       http.StatusNotFound,
+	apiErrorMethodNotAllowed: http.StatusMethodNotAllowed,
+	apiErrorIdempotencyKey:   http.StatusUnprocessableEntity,
+	apiErrorRateLimited:      http.StatusTooManyRequests,
+	apiErrorUnavailable:      http.StatusServiceUnavailable,
+	apiErrorGatewayTimeout:   http.StatusGatewayTimeout,
+	apiErrorInternal:         http.StatusInternalServerError,
+}
+
+func (e *apiError) Error() string {
+	// The backend's errors read the same whichever side of the API they
+	// happened on. Anything else says where it came from.
+	if _, ok := backendErrorCodes[e.Code]; ok {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// Unwrap returns the backend error for the code, so errors.Is(err,
+// errNotFound) works the same for the API as for the local backend.
+func (e *apiError) Unwrap() error {
+	return backendErrorCodes[e.Code]
+}
+
+// Bodies of the calls that change part of an object. Changes to a
+// mock_example carry the revision they're based on (see 'revision' in
+// resource_mock_example.go).
+type (
+	counterValueRequest struct {
+		Value int `json:"value"`
+	}
+
+	secretValueRequest struct {
+		Value string `json:"value"`
+	}
+
+	exampleFieldsRequest struct {
+		Revision            int               `json:"revision"`
+		NotComputedOptional string            `json:"not_computed_optional,omitempty"`
+		NotComputedRequired string            `json:"not_computed_required"`
+		Tags                map[string]string `json:"tags,omitempty"`
+	}
+
+	exampleFooRequest struct {
+		Revision int          `json:"revision"`
+		Foo      []exampleFoo `json:"foo"`
+	}
+
+	exampleFooPatchRequest struct {
+		Revision int          `json:"revision"`
+		Ops      []fooPatchOp `json:"ops"`
+	}
+
+	exampleBazRequest struct {
+		Revision int          `json:"revision"`
+		Baz      []exampleBaz `json:"baz"`
+	}
+
+	exampleSomeListRequest struct {
+		Revision int      `json:"revision"`
+		SomeList []string `json:"some_list"`
+	}
+)
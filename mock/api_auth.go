@@ -0,0 +1,293 @@
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The mock API server can require every request to carry a bearer token:
+//
+//	Authorization: Bearer <token>
+//
+// The tokens it accepts are read from a JSON file (see the -tokens-file flag
+// of ServeAPI) that looks like this:
+//
+//	{
+//	  "tokens": [
+//	    {"name": "ci", "token": "...", "scope": "read-write"},
+//	    {"name": "auditor", "token": "...", "scope": "read-only"}
+//	  ],
+//	  "clients": [
+//	    {"client_id": "deployer", "client_secret": "...", "scope": "read-write"}
+//	  ]
+//	}
+//
+// The 'tokens' never expire. The 'clients' get short-lived tokens instead,
+// from the OAuth2 token endpoint (see issueToken), which is how a lot of real
+// APIs work.
+//
+// A read-only token can make GET requests, and nothing else, which makes it
+// easy to see what the provider does when it's allowed to read an object but
+// not to change or delete it.
+//
+// Without a tokens file every request is allowed, as before.
+
+// The scopes a token can have.
+const (
+	tokenScopeReadOnly  = "read-only"
+	tokenScopeReadWrite = "read-write"
+)
+
+// oauthTokenPath is the path of the OAuth2 token endpoint. It isn't under /v1
+// because it isn't part of the API as such, and it doesn't need a token.
+const oauthTokenPath = "/oauth/token"
+
+// defaultTokenLifetime is how long a token issued by the token endpoint lasts
+// when the -token-lifetime flag isn't given.
+const defaultTokenLifetime = time.Hour
+
+// apiToken is a token the server accepts. It's also the body of the response
+// to GET /v1/whoami (without the token itself), which the provider calls
+// when it's configured to check that its token works.
+type apiToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token,omitempty"`
+	Scope string `json:"scope"`
+}
+
+// oauthClient is a client that can get tokens from the token endpoint.
+type oauthClient struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Scope        string `json:"scope"`
+}
+
+type apiTokensFile struct {
+	Tokens  []apiToken    `json:"tokens"`
+	Clients []oauthClient `json:"clients"`
+}
+
+// apiAuth is everything the server needs to know to authenticate a request.
+type apiAuth struct {
+	tokens   map[string]*apiToken
+	clients  map[string]*oauthClient
+	lifetime time.Duration
+
+	// issued are the tokens the token endpoint has issued, which are only
+	// kept in memory (so restarting the server revokes them all).
+	mu     sync.Mutex
+	issued map[string]*issuedToken
+}
+
+type issuedToken struct {
+	token   *apiToken
+	expires time.Time
+}
+
+// loadAPIAuth reads a tokens file. lifetime is how long the tokens issued to
+// its clients last.
+func loadAPIAuth(path string, lifetime time.Duration) (*apiAuth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+
+	var f apiTokensFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode tokens file %s: %w", path, err)
+	}
+
+	a := &apiAuth{
+		tokens:   make(map[string]*apiToken, len(f.Tokens)),
+		clients:  make(map[string]*oauthClient, len(f.Clients)),
+		lifetime: lifetime,
+		issued:   make(map[string]*issuedToken),
+	}
+	for i := range f.Tokens {
+		t := &f.Tokens[i]
+		if t.Token == "" {
+			return nil, fmt.Errorf("tokens file %s: token %q has no token", path, t.Name)
+		}
+		if err := validateTokenScope(t.Scope); err != nil {
+			return nil, fmt.Errorf("tokens file %s: token %q %w", path, t.Name, err)
+		}
+		if _, ok := a.tokens[t.Token]; ok {
+			return nil, fmt.Errorf("tokens file %s: token %q is the same as another token", path, t.Name)
+		}
+		a.tokens[t.Token] = t
+	}
+	for i := range f.Clients {
+		c := &f.Clients[i]
+		if c.ClientID == "" || c.ClientSecret == "" {
+			return nil, fmt.Errorf("tokens file %s: every client needs a client_id and a client_secret", path)
+		}
+		if err := validateTokenScope(c.Scope); err != nil {
+			return nil, fmt.Errorf("tokens file %s: client %q %w", path, c.ClientID, err)
+		}
+		if _, ok := a.clients[c.ClientID]; ok {
+			return nil, fmt.Errorf("tokens file %s: client %q is defined twice", path, c.ClientID)
+		}
+		a.clients[c.ClientID] = c
+	}
+	if len(a.tokens) == 0 && len(a.clients) == 0 {
+		return nil, fmt.Errorf("tokens file %s doesn't define any tokens or clients", path)
+	}
+	return a, nil
+}
+
+func validateTokenScope(scope string) error {
+	if scope != tokenScopeReadOnly && scope != tokenScopeReadWrite {
+		return fmt.Errorf("has scope %q, which should be %q or %q", scope, tokenScopeReadOnly, tokenScopeReadWrite)
+	}
+	return nil
+}
+
+// authenticate returns the token a request was made with. It's nil when the
+// server doesn't require one.
+func (s *apiServer) authenticate(r *http.Request) (*apiToken, *apiError) {
+	if s.auth == nil {
+		return nil, nil
+	}
+
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, &apiError{Code: apiErrorUnauthorized, Message: "the request has no bearer token"}
+	}
+	if t, ok := s.auth.tokens[token]; ok {
+		return t, nil
+	}
+
+	s.auth.mu.Lock()
+	defer s.auth.mu.Unlock()
+
+	now := time.Now()
+	for k, issued := range s.auth.issued {
+		if now.After(issued.expires.Add(time.Hour)) {
+			delete(s.auth.issued, k)
+		}
+	}
+	issued, ok := s.auth.issued[token]
+	if !ok {
+		return nil, &apiError{Code: apiErrorUnauthorized, Message: "the bearer token is invalid"}
+	}
+	if !now.Before(issued.expires) {
+		return nil, &apiError{
+			Code:    apiErrorUnauthorized,
+			Message: fmt.Sprintf("the access token expired %s ago", now.Sub(issued.expires).Round(time.Millisecond)),
+		}
+	}
+	return issued.token, nil
+}
+
+// authorize checks that the token a request was made with is allowed to make
+// it.
+func authorize(t *apiToken, r *http.Request) *apiError {
+	if t == nil || t.Scope == tokenScopeReadWrite || r.Method == http.MethodGet {
+		return nil
+	}
+	return &apiError{
+		Code:    apiErrorForbidden,
+		Message: fmt.Sprintf("permission denied: token %q is %s and can't %s %s", t.Name, t.Scope, r.Method, r.URL.Path),
+	}
+}
+
+// unauthorizedHeader tells the client how to authenticate, as a 401 should.
+func unauthorizedHeader() http.Header {
+	return http.Header{"Www-Authenticate": []string{`Bearer realm="terraform-provider-mock"`}}
+}
+
+// oauthTokenResponse is the body of a successful response from the token
+// endpoint (RFC 6749, section 5.1).
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// oauthErrorResponse is the body of an error response from the token
+// endpoint (RFC 6749, section 5.2). It's a different shape to apiError
+// because that's what the standard says, and real OAuth2 clients expect it.
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// issueToken is the OAuth2 token endpoint. It only supports the
+// 'client_credentials' grant, where a client swaps its ID and secret for an
+// access token that expires after the server's token lifetime.
+//
+// NOTE: A client can send its credentials in the form body or with HTTP
+// Basic authentication. The standard says servers have to support the
+// latter, and plenty of clients only do the former.
+func (s *apiServer) issueToken(r *http.Request) (int, http.Header, []byte) {
+	oauthError := func(status int, code, description string) (int, http.Header, []byte) {
+		body, _ := json.Marshal(oauthErrorResponse{Error: code, ErrorDescription: description})
+		header := http.Header{"Cache-Control": []string{"no-store"}}
+		if status == http.StatusUnauthorized {
+			header.Set("Www-Authenticate", `Basic realm="terraform-provider-mock"`)
+		}
+		return status, header, body
+	}
+
+	if r.Method != http.MethodPost {
+		return s.errorResponse(&apiError{Code: apiErrorMethodNotAllowed, Message: fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path)}, nil)
+	}
+	if err := r.ParseForm(); err != nil {
+		return oauthError(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	if grantType := r.PostForm.Get("grant_type"); grantType != "client_credentials" {
+		return oauthError(http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("grant_type %q isn't supported, only \"client_credentials\" is", grantType))
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	var client *oauthClient
+	if s.auth != nil {
+		client = s.auth.clients[clientID]
+	}
+	if client == nil || client.ClientSecret != clientSecret {
+		return oauthError(http.StatusUnauthorized, "invalid_client", "unknown client_id or wrong client_secret")
+	}
+
+	token, err := generateAccessToken()
+	if err != nil {
+		return oauthError(http.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	s.auth.mu.Lock()
+	s.auth.issued[token] = &issuedToken{
+		token:   &apiToken{Name: client.ClientID, Scope: client.Scope},
+		expires: time.Now().Add(s.auth.lifetime),
+	}
+	s.auth.mu.Unlock()
+	log.Printf(">>> issued an access token to client %q, which expires in %s", client.ClientID, s.auth.lifetime)
+
+	body, _ := json.Marshal(oauthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.auth.lifetime.Seconds()),
+		Scope:       client.Scope,
+	})
+	return http.StatusOK, http.Header{"Cache-Control": []string{"no-store"}}, body
+}
+
+// generateAccessToken returns a random access token.
+func generateAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+	return "mock_at_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,71 @@
+package mock
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+)
+
+func TestAPITokenDiagnostics(t *testing.T) {
+	s := newAPIServer(newBackend(""), apiServerOptions{})
+	s.auth = &apiAuth{
+		tokens: map[string]*apiToken{
+			"ci-token":      {Name: "ci", Token: "ci-token", Scope: tokenScopeReadWrite},
+			"auditor-token": {Name: "auditor", Token: "auditor-token", Scope: tokenScopeReadOnly},
+		},
+		clients:  map[string]*oauthClient{},
+		lifetime: time.Minute,
+		issued:   map[string]*issuedToken{},
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	url := regexp.QuoteMeta(server.URL)
+
+	t.Run("wrong token", func(t *testing.T) {
+		testProviderEnv(t)
+		_, diags := configureTestGRPCProvider(t, map[string]any{"api_url": server.URL, "token": "wrong"})
+		testExpectError(t, diags, "^Invalid mock API token$",
+			"^The mock API server at "+url+" rejected the provider's token: the bearer token is invalid\\.\n\nCheck `token` against the server's tokens file\\.$")
+	})
+
+	t.Run("wrong token from the environment", func(t *testing.T) {
+		testProviderEnv(t)
+		t.Setenv("MOCK_API_TOKEN", "wrong")
+		_, diags := configureTestGRPCProvider(t, map[string]any{"api_url": server.URL})
+		testExpectError(t, diags, "^Invalid mock API token$", "rejected the provider's token(?s).*`token` was set by the MOCK_API_TOKEN environment variable\\.$")
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		testProviderEnv(t)
+		_, diags := configureTestGRPCProvider(t, map[string]any{"api_url": server.URL})
+		testExpectError(t, diags, "^Invalid mock API token$", "^The mock API server at "+url+" needs a token\\.\n\nSet `token`")
+	})
+
+	t.Run("read-only token", func(t *testing.T) {
+		testProviderEnv(t)
+		rw := newTestGRPCProvider(t, map[string]any{"api_url": server.URL, "token": "ci-token"})
+		parent := rw.apply("mock_parent", cty.NilVal, map[string]any{"name": "parent"})
+
+		// A read-only token is only a warning when configuring, because it
+		// can still plan...
+		p, diags := configureTestGRPCProvider(t, map[string]any{"api_url": server.URL, "token": "auditor-token"})
+		p.check("configure", diags)
+		if len(diags) != 1 || diags[0].Severity != tfprotov5.DiagnosticSeverityWarning || diags[0].Summary != "The mock API token is read-only" ||
+			!regexp.MustCompile(`^Token "auditor" can read objects but not create, change or delete them`).MatchString(diags[0].Detail) {
+			t.Fatalf("expected a warning about the read-only token, got %v", diags)
+		}
+		p.read("mock_parent", parent)
+
+		// ...but can't apply anything.
+		id := parent.GetAttr("id").AsString()
+		testExpectError(t, p.tryDestroy("mock_parent", parent),
+			`^permission denied: token "auditor" is read-only and can't DELETE /v1/parents/`+id+` \(HTTP 403\)$`, "^$")
+		if state := rw.read("mock_parent", parent); state.IsNull() {
+			t.Fatal("expected the parent to still exist")
+		}
+	})
+}
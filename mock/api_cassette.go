@@ -0,0 +1,525 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A cassette is a recording of the requests the provider made to the mock API
+// server, and the responses it got (the name comes from the Ruby library VCR,
+// which most tools like this copy).
+//
+// With 'cassette_mode = "record"' every request goes to the server as usual,
+// and is written to 'cassette_file' along with its response. With
+// 'cassette_mode = "replay"' nothing is sent anywhere: each request is
+// answered with the response recorded for it. A sequence of applies that
+// happened once against a server (with all its rate limiting, lost responses
+// and expired tokens) can then be repeated exactly, without a server, as a
+// regression test of the CRUD functions.
+//
+// NOTE: Terraform starts a new provider process for every command, and often
+// more than one per command (e.g. one to plan and another to apply), so
+// nothing about a cassette can be kept in memory. The cassette is read and
+// written again for every request, with a lock file (see lock), so that two
+// processes can't both change it at the same time, just as backend.lock does
+// for the backend file. How far a replay has got is kept in a second file
+// next to the cassette (see replayedPath), and the replay starts over once it
+// has got to the end (see prepare).
+
+const (
+	cassetteModeRecord = "record"
+	cassetteModeReplay = "replay"
+)
+
+// cassetteReplayURL is the 'api_url' when a cassette is replayed without
+// one. Nothing is ever sent to it.
+const cassetteReplayURL = "http://cassette.invalid"
+
+// cassetteFile is the content of a cassette file.
+type cassetteFile struct {
+	Interactions []*cassetteInteraction `json:"interactions"`
+}
+
+// cassetteInteraction is a request and what came back: either a response, or
+// the error of a request that never got one.
+type cassetteInteraction struct {
+	Request  cassetteRequest   `json:"request"`
+	Response *cassetteResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// cassetteRequest is what a request is recognised by when it's replayed.
+//
+// NOTE: Headers aren't part of it. The idempotency key is different every
+// time, and the Authorization header is a secret that has no business being
+// in a file that's going to be committed. For the same reason the body is
+// recorded with its secrets redacted (see redactBody), and a request is
+// matched against a recording by redacting it the same way.
+type cassetteRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body,omitempty"`
+}
+
+// cassetteResponse is a recorded response.
+//
+// NOTE: A response is recorded exactly as it was received, apart from the
+// headers that are different every time, because replaying it faithfully is
+// the whole point. So a cassette recorded against a server with real data
+// can contain secrets (e.g. the 'api_key' of a mock_secret) and should be
+// looked at before it's committed.
+type cassetteResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// cassetteReplayed is the content of the file that says which interactions
+// of a cassette have been replayed.
+type cassetteReplayed struct {
+	Interactions []int `json:"interactions"`
+
+	// IDs maps each ID the provider made up during the replay to the one it
+	// made up in its place while recording (see matchNewIDs).
+	IDs map[string]string `json:"ids"`
+}
+
+// cassette records or replays requests, depending on its mode.
+type cassette struct {
+	path string
+	mode string
+
+	// mu stops parallel requests in this process from reading and writing
+	// the files at the same time. The lock file does the same for other
+	// processes (see lock).
+	mu sync.Mutex
+}
+
+func newCassette(path, mode string) *cassette {
+	return &cassette{path: path, mode: mode}
+}
+
+// replayedPath is the file that says which of the cassette's interactions
+// have been replayed.
+func (c *cassette) replayedPath() string {
+	return c.path + ".replayed"
+}
+
+// lockPath is the file that's locked while the cassette's files are read
+// and written. It's never removed, as removing it would let two processes
+// lock different files with the same name.
+func (c *cassette) lockPath() string {
+	return c.path + ".lock"
+}
+
+// lock stops other goroutines, and other provider processes, from reading
+// and writing the cassette's files until unlock is called.
+func (c *cassette) lock() (unlock func(), err error) {
+	c.mu.Lock()
+	unlockFile, err := lockFile(c.lockPath())
+	if err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to lock cassette %s: %w", c.path, err)
+	}
+	return func() {
+		unlockFile()
+		c.mu.Unlock()
+	}, nil
+}
+
+// transport returns the http.RoundTripper that records or replays requests.
+// When recording, next is what actually sends them.
+func (c *cassette) transport(next http.RoundTripper) http.RoundTripper {
+	if c.mode == cassetteModeReplay {
+		next = nil
+	}
+	return &cassetteTransport{cassette: c, next: next}
+}
+
+type cassetteTransport struct {
+	cassette *cassette
+	next     http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := cassetteRequest{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Body:   redactBody(body),
+	}
+
+	if t.next == nil {
+		return t.cassette.replay(req, recorded)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		// Giving up on a request (e.g. because the user pressed Ctrl-C) is
+		// up to the provider, not the server, so it isn't recorded.
+		if req.Context().Err() == nil {
+			if recordErr := t.cassette.record(&cassetteInteraction{Request: recorded, Error: err.Error()}); recordErr != nil {
+				return nil, recordErr
+			}
+		}
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	for _, k := range []string{"Date", "Content-Length"} {
+		header.Del(k)
+	}
+	for _, k := range sensitiveHeaders {
+		if header.Get(k) != "" {
+			header.Set(k, redacted)
+		}
+	}
+	err = t.cassette.record(&cassetteInteraction{
+		Request: recorded,
+		Response: &cassetteResponse{
+			Status: resp.StatusCode,
+			Header: header,
+			Body:   string(respBody),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// readRequestBody returns the body of req without consuming it.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// record adds an interaction to the end of the cassette.
+func (c *cassette) record(i *cassetteInteraction) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := c.load()
+	if err != nil {
+		return err
+	}
+	f.Interactions = append(f.Interactions, i)
+	return c.save(c.path, f)
+}
+
+// replay answers a request with the first interaction that was recorded for
+// it and hasn't been replayed yet.
+//
+// NOTE: Interactions are matched on their request rather than simply
+// replayed in order, because terraform makes requests in parallel, and the
+// order of the requests for different resources changes from one run to the
+// next. The requests for any one resource always happen in the same order,
+// which is what matters: the first GET of an object gets the first response
+// recorded for it, and the GET after the object was changed gets the changed
+// object.
+func (c *cassette) replay(req *http.Request, live cassetteRequest) (*http.Response, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	f, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	replayed, err := c.loadReplayed()
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(replayed.Interactions))
+	for _, i := range replayed.Interactions {
+		done[i] = true
+	}
+
+	// A request that matches a recording once the IDs we already know about
+	// are translated is always preferred. Only when there isn't one is a new
+	// ID matched with whatever ID the recording has in its place.
+	found, learned := -1, map[string]string(nil)
+	translated := cassetteRequest{Method: live.Method, Path: replayed.toRecorded(live.Path), Body: replayed.toRecorded(live.Body)}
+	for i, interaction := range f.Interactions {
+		if !done[i] && interaction.Request == translated {
+			found = i
+			break
+		}
+	}
+	for i, interaction := range f.Interactions {
+		if found >= 0 {
+			break
+		}
+		if ids, ok := replayed.matchNewIDs(live, interaction.Request); ok && !done[i] {
+			found, learned = i, ids
+		}
+	}
+	if found < 0 {
+		return nil, &cassetteMissError{cassette: c, request: live, replayed: len(replayed.Interactions), total: len(f.Interactions)}
+	}
+
+	for liveID, recordedID := range learned {
+		log.Printf(">>> %s was %s when the cassette was recorded", liveID, recordedID)
+		replayed.IDs[liveID] = recordedID
+	}
+	replayed.Interactions = append(replayed.Interactions, found)
+	if err := c.save(c.replayedPath(), replayed); err != nil {
+		return nil, err
+	}
+	log.Printf(">>> replaying interaction %d of %s: %s %s", found+1, c.path, live.Method, live.Path)
+
+	interaction := f.Interactions[found]
+	if interaction.Response == nil {
+		return nil, errors.New(interaction.Error)
+	}
+	r := interaction.Response
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	body := replayed.toLive(r.Body)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// cassetteMissError is the error for a request that's not in the cassette.
+// It isn't worth retrying, as it'll be missing every time.
+type cassetteMissError struct {
+	cassette        *cassette
+	request         cassetteRequest
+	replayed, total int
+}
+
+func (e *cassetteMissError) Error() string {
+	return fmt.Sprintf("the cassette %s has no recording of %s %s that hasn't already been replayed (%d of its %d interactions have been). "+
+		"The provider made a request it didn't make when the cassette was recorded, or an earlier replay stopped part of the way through: delete %s to replay it from the beginning",
+		e.cassette.path, e.request.Method, e.request.Path, e.replayed, e.total, e.cassette.replayedPath())
+}
+
+// uuidPattern matches the IDs the provider makes up for new objects (see
+// e.g. resourceParentCreate), and the ones the server makes up, which look
+// the same.
+var uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// toRecorded replaces the IDs in s that the provider made up during the
+// replay with the ones it made up while recording.
+func (r *cassetteReplayed) toRecorded(s string) string {
+	return uuidPattern.ReplaceAllStringFunc(s, func(id string) string {
+		if recordedID, ok := r.IDs[id]; ok {
+			return recordedID
+		}
+		return id
+	})
+}
+
+// toLive does the opposite of toRecorded, so that the provider gets back the
+// IDs it sent.
+func (r *cassetteReplayed) toLive(s string) string {
+	live := make(map[string]string, len(r.IDs))
+	for liveID, recordedID := range r.IDs {
+		live[recordedID] = liveID
+	}
+	return uuidPattern.ReplaceAllStringFunc(s, func(id string) string {
+		if liveID, ok := live[id]; ok {
+			return liveID
+		}
+		return id
+	})
+}
+
+// matchNewIDs reports whether a request matches a recorded one if each of
+// the IDs in it that we don't know about yet stands for the ID in the same
+// place in the recording, returning what those IDs stand for.
+//
+// NOTE: This is what lets a cassette be replayed at all. The provider makes
+// up a new ID for every object it creates (e.g. the 'id' in the body of POST
+// /v1/parents), so no request it makes during a replay is quite the same as
+// the one it made while recording. Once the create has been matched, every
+// later request for the object is translated to the recorded ID, and every
+// response back to the new one.
+func (r *cassetteReplayed) matchNewIDs(live, recorded cassetteRequest) (map[string]string, bool) {
+	if live.Method != recorded.Method {
+		return nil, false
+	}
+
+	learned := map[string]string{}
+	for _, pair := range [][2]string{{live.Path, recorded.Path}, {live.Body, recorded.Body}} {
+		var pattern strings.Builder
+		var unknown []string
+		last := 0
+		for _, loc := range uuidPattern.FindAllStringIndex(pair[0], -1) {
+			pattern.WriteString(regexp.QuoteMeta(pair[0][last:loc[0]]))
+			id := pair[0][loc[0]:loc[1]]
+			if recordedID, ok := r.IDs[id]; ok {
+				pattern.WriteString(regexp.QuoteMeta(recordedID))
+			} else {
+				pattern.WriteString("(" + uuidPattern.String() + ")")
+				unknown = append(unknown, id)
+			}
+			last = loc[1]
+		}
+		pattern.WriteString(regexp.QuoteMeta(pair[0][last:]))
+
+		m := regexp.MustCompile("^" + pattern.String() + "$").FindStringSubmatch(pair[1])
+		if m == nil {
+			return nil, false
+		}
+		for i, id := range unknown {
+			recordedID := m[i+1]
+			if prev, ok := learned[id]; ok && prev != recordedID {
+				return nil, false
+			}
+			// Two objects can't have been the same object when recording.
+			for _, ids := range []map[string]string{r.IDs, learned} {
+				for otherID, otherRecordedID := range ids {
+					if otherRecordedID == recordedID && otherID != id {
+						return nil, false
+					}
+				}
+			}
+			learned[id] = recordedID
+		}
+	}
+	return learned, len(learned) > 0
+}
+
+func (c *cassette) load() (*cassetteFile, error) {
+	var f cassetteFile
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) && c.mode == cassetteModeRecord {
+		return &f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %s: %w", c.path, err)
+	}
+	return &f, nil
+}
+
+func (c *cassette) loadReplayed() (*cassetteReplayed, error) {
+	replayed := cassetteReplayed{IDs: map[string]string{}}
+	data, err := os.ReadFile(c.replayedPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &replayed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette progress: %w", err)
+	}
+	if err := json.Unmarshal(data, &replayed); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette progress %s: %w", c.replayedPath(), err)
+	}
+	if replayed.IDs == nil {
+		replayed.IDs = map[string]string{}
+	}
+	return &replayed, nil
+}
+
+func (c *cassette) save(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// prepare is called when the provider is configured. It checks that a
+// cassette can be replayed before the provider starts, so that a missing or
+// broken cassette is reported once rather than as the error of every
+// request, and works out where the replay starts:
+//
+//   - A replay that got to the end of the cassette starts again from the
+//     beginning, so that the same cassette can be replayed again and again
+//     (e.g. by a test).
+//   - Recording changes the cassette, so the progress of an earlier replay
+//     of it no longer means anything and is thrown away.
+//
+// NOTE: The progress can't simply be thrown away every time the provider is
+// configured, because that happens more than once per terraform command (a
+// plan and the apply that follows it are separate provider processes), and
+// a cassette can cover several commands. Only the end of a replay is a safe
+// place to start another one.
+func (c *cassette) prepare() error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if c.mode == cassetteModeRecord {
+		if err := os.Remove(c.replayedPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to reset cassette progress: %w", err)
+		}
+		return nil
+	}
+
+	f, err := c.load()
+	if err != nil {
+		return err
+	}
+	replayed, err := c.loadReplayed()
+	if err != nil {
+		return err
+	}
+	if n := len(replayed.Interactions); n > 0 && n >= len(f.Interactions) {
+		log.Printf(">>> every interaction of %s has been replayed, replaying it from the beginning", c.path)
+		if err := os.Remove(c.replayedPath()); err != nil {
+			return fmt.Errorf("failed to reset cassette progress: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+// testCassette is a recording of testCassetteSteps against the mock API
+// server. Replaying it runs the CRUD functions of mock_parent with exactly
+// the responses they got back then, without a server, so it's a regression
+// test of how they handle them.
+//
+// To record it again (e.g. after changing what the provider sends), run:
+//
+//	MOCK_RECORD_CASSETTE=1 go test ./mock -run TestCassette
+const testCassette = "testdata/cassette.json"
+
+func TestCassette(t *testing.T) {
+	testProviderEnv(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	if os.Getenv("MOCK_RECORD_CASSETTE") != "" {
+		server := httptest.NewServer(newAPIServer(newBackend(""), apiServerOptions{}))
+		defer server.Close()
+
+		testCassetteSteps(t, map[string]any{
+			"api_url":       server.URL,
+			"cassette_file": path,
+			"cassette_mode": cassetteModeRecord,
+		})
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(testCassette, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// The cassette is replayed from a copy, so that the progress of the
+	// replay is kept out of the repository.
+	data, err := os.ReadFile(testCassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]any{
+		"cassette_file": path,
+		"cassette_mode": cassetteModeReplay,
+	}
+
+	// A replay that got to the end starts again from the beginning, so the
+	// second one works just like the first.
+	for _, run := range []string{"first replay", "second replay"} {
+		t.Run(run, func(t *testing.T) {
+			testCassetteSteps(t, config)
+		})
+	}
+}
+
+func TestCassetteRecordLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// Each cassette has a mutex of its own, like the cassette of each
+	// provider process, so only the lock file stops them from losing each
+	// other's interactions.
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := newCassette(path, cassetteModeRecord)
+			errs <- c.record(&cassetteInteraction{Request: cassetteRequest{Method: http.MethodGet, Path: fmt.Sprintf("/v1/counters/%d", i)}})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := newCassette(path, cassetteModeReplay).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Interactions) != n {
+		t.Errorf("expected %d interactions, got %d", n, len(f.Interactions))
+	}
+}
+
+// testCassetteSteps creates, renames and destroys a mock_parent, checking
+// what the provider makes of the responses. Each step gets a provider of its
+// own, like each terraform command does.
+func testCassetteSteps(t *testing.T, config map[string]any) {
+	p := newTestGRPCProvider(t, config)
+	state := p.apply("mock_parent", cty.NilVal, map[string]any{"name": "cassette"})
+	if name := state.GetAttr("name"); !name.RawEquals(cty.StringVal("cassette")) {
+		t.Fatalf("expected the parent to be created with its name, got %#v", name)
+	}
+
+	p = newTestGRPCProvider(t, config)
+	state = p.read("mock_parent", state)
+	state = p.apply("mock_parent", state, map[string]any{"name": "renamed"})
+
+	p = newTestGRPCProvider(t, config)
+	state = p.read("mock_parent", state)
+	if name := state.GetAttr("name"); !name.RawEquals(cty.StringVal("renamed")) {
+		t.Fatalf("expected the parent to have been renamed, got %#v", name)
+	}
+	p.destroy("mock_parent", state)
+
+	p = newTestGRPCProvider(t, config)
+	if state = p.read("mock_parent", state); !state.IsNull() {
+		t.Fatalf("expected the parent to be gone, got %#v", state)
+	}
+}
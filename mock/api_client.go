@@ -0,0 +1,560 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiClient implements backendAPI by calling the mock API server (see
+// api_server.go). It's what the provider uses when 'api_url' is set.
+//
+// Talking to the backend over a network means a call can fail for reasons
+// that have nothing to do with the call itself, and the client deals with
+// them the way any API client should:
+//
+//   - 429 (rate limited) and 5xx responses, and requests that never got a
+//     response at all, are retried up to 'max_attempts' times.
+//   - Between attempts the client waits for an exponentially increasing
+//     amount of time, with 'jitter' so that clients that failed at the same
+//     time don't all retry at the same time too. If the server says how long
+//     to wait (with a Retry-After header), the client waits that long instead.
+//   - Every request that changes something carries an idempotency key, which
+//     is the same for every attempt (see below).
+type apiClient struct {
+	baseURL string
+	http    *http.Client
+	retry   retryPolicy
+
+	// Requests are authenticated with either a static token or one from the
+	// server's OAuth2 token endpoint.
+	token string
+	oauth *oauthTokenSource
+}
+
+// apiClientOptions are the provider arguments that configure the client.
+type apiClientOptions struct {
+	// MaxAttempts is the 'max_attempts' argument (see retryPolicy).
+	MaxAttempts int
+
+	// Token is sent as a bearer token with every request, if it's set.
+	Token string
+
+	// ClientID and ClientSecret are used to get tokens from the server's
+	// OAuth2 token endpoint instead, if they're set.
+	ClientID     string
+	ClientSecret string
+
+	// TLSConfig is used for https:// URLs, when the defaults won't do (see
+	// clientTLSConfig).
+	TLSConfig *tls.Config
+
+	// Cassette records the client's requests, or replays them instead of
+	// sending them (see api_cassette.go), if it's set.
+	Cassette *cassette
+}
+
+// retryPolicy decides how often, and how long after each other, the attempts
+// at a request are made.
+type retryPolicy struct {
+	// MaxAttempts is how many times a request is made before giving up,
+	// including the first attempt.
+	MaxAttempts int
+
+	// MinWait is the wait before the second attempt, which doubles for every
+	// attempt after that up to MaxWait. MaxWait also caps how long the
+	// client is willing to wait when it's told to by a Retry-After header.
+	MinWait time.Duration
+	MaxWait time.Duration
+}
+
+// defaultMaxAttempts is used when 'max_attempts' isn't set.
+const defaultMaxAttempts = 4
+
+func newAPIClient(baseURL string, opts apiClientOptions) *apiClient {
+	transport := http.DefaultTransport
+	if opts.TLSConfig != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = opts.TLSConfig
+		transport = t
+	}
+	if opts.Cassette != nil {
+		transport = opts.Cassette.transport(transport)
+	}
+
+	c := &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: newLoggingTransport(transport),
+		},
+		retry: retryPolicy{
+			MaxAttempts: opts.MaxAttempts,
+			MinWait:     500 * time.Millisecond,
+			MaxWait:     30 * time.Second,
+		},
+		token: opts.Token,
+	}
+	if opts.Cassette != nil && opts.Cassette.mode == cassetteModeReplay {
+		// There's no server to be kind to, so a replayed retry happens
+		// straight away.
+		c.retry.MinWait, c.retry.MaxWait = 0, 0
+	}
+	if opts.ClientID != "" {
+		c.oauth = &oauthTokenSource{
+			tokenURL:     c.baseURL + oauthTokenPath,
+			clientID:     opts.ClientID,
+			clientSecret: opts.ClientSecret,
+			http:         c.http,
+		}
+	}
+	return c
+}
+
+// do sends a request with in as its JSON body (unless it's nil), and decodes
+// the JSON body of the response into out (unless it's nil).
+func (c *apiClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	// NOTE: If a request times out, or the response is a 5xx, we can't tell
+	// whether the server made the change before failing. Retrying a create
+	// could then create a second object, and retrying an update could fail
+	// with a conflict caused by the update itself (as it bumped the revision).
+	//
+	// The idempotency key tells the server that every attempt is the same
+	// request, and if it has already processed it, it sends back the
+	// response it recorded instead of processing it again. That's why it's
+	// generated once per call, not once per attempt.
+	var idempotencyKey string
+	if method != http.MethodGet {
+		idempotencyKey = uuid.New().String()
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body, idempotencyKey)
+		if err != nil {
+			// The context is cancelled when the user presses Ctrl-C, which is
+			// no reason to try again.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Nor is being refused a token (e.g. because the client_secret
+			// is wrong), a certificate that isn't trusted, or a request
+			// that isn't in the cassette being replayed.
+			var oauthErr *oauthError
+			if errors.As(err, &oauthErr) && !retryableStatus(oauthErr.Status) {
+				return err
+			}
+			var missErr *cassetteMissError
+			if tlsErrorHint(err) != "" || errors.As(err, &missErr) {
+				return err
+			}
+			lastErr = err
+		} else {
+			done, err := c.handleResponse(resp, out)
+			if done {
+				return err
+			}
+			lastErr = err
+		}
+
+		if attempt >= c.retry.MaxAttempts {
+			if attempt > 1 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, lastErr)
+			}
+			return lastErr
+		}
+
+		wait := c.retry.backoff(attempt)
+		if resp != nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = d
+				if wait > c.retry.MaxWait {
+					wait = c.retry.MaxWait
+				}
+			}
+		}
+		log.Printf(">>> %s, retrying in %s (attempt %d of %d)", lastErr, wait.Round(time.Millisecond), attempt+1, c.retry.MaxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes a single attempt at a request.
+func (c *apiClient) send(ctx context.Context, method, path string, body []byte, idempotencyKey string) (*http.Response, error) {
+	resp, token, err := c.sendWithToken(ctx, method, path, body, idempotencyKey)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.oauth == nil {
+		return resp, err
+	}
+
+	// The access token was rejected even though it shouldn't have expired
+	// yet, e.g. because the server was restarted (and forgot the tokens it
+	// had issued) or because its clock is ahead of ours. Another token
+	// should work, and if it doesn't, the 401 is the error to report.
+	//
+	// NOTE: This doesn't count as another attempt, as nothing went wrong
+	// that waiting would fix.
+	resp.Body.Close()
+	c.oauth.invalidate(token)
+	resp, _, err = c.sendWithToken(ctx, method, path, body, idempotencyKey)
+	return resp, err
+}
+
+// sendWithToken sends a request, returning the token it was authenticated
+// with.
+func (c *apiClient) sendWithToken(ctx context.Context, method, path string, body []byte, idempotencyKey string) (*http.Response, string, error) {
+	token := c.token
+	if c.oauth != nil {
+		var err error
+		if token, err = c.oauth.get(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "terraform-provider-mock")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := c.http.Do(req)
+	return resp, token, err
+}
+
+// handleResponse decodes the response into out. It returns false if the
+// request should be retried, along with the error to report if it isn't.
+func (c *apiClient) handleResponse(resp *http.Response, out any) (bool, error) {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read response from %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil || len(body) == 0 {
+			return true, nil
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return true, fmt.Errorf("failed to decode response from %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+		}
+		return true, nil
+	}
+
+	err = decodeAPIError(resp.StatusCode, body)
+	return !retryableStatus(resp.StatusCode), err
+}
+
+// retryableStatus reports whether a request that failed with the given
+// status is worth trying again.
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		(status >= http.StatusInternalServerError && status != http.StatusNotImplemented)
+}
+
+// decodeAPIError turns an error response back into the error the backend
+// returned.
+func decodeAPIError(status int, body []byte) error {
+	var resp apiErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error == nil {
+		// e.g. an HTML error page from a proxy in between.
+		return &apiError{Status: status, Code: apiErrorInternal, Message: strings.TrimSpace(string(body))}
+	}
+
+	e := resp.Error
+	e.Status = status
+	if e.Code == "already_exists" && e.Kind != "" {
+		return &alreadyExistsError{kind: e.Kind, name: e.Name, id: e.ID}
+	}
+	return e
+}
+
+// backoff returns how long to wait after the given attempt failed: MinWait
+// doubled for each attempt, up to MaxWait, of which a random half is used.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.MaxWait
+	if shift := attempt - 1; shift < 16 {
+		if exp := p.MinWait << shift; exp < d {
+			d = exp
+		}
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// apiPath builds a request path from a format string, escaping the arguments.
+func apiPath(format string, args ...string) string {
+	escaped := make([]any, len(args))
+	for i, a := range args {
+		escaped[i] = url.PathEscape(a)
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// whoami returns the name and scope of the client's token. It isn't part of
+// backendAPI: the provider calls it when it's configured, to find out whether
+// the token works before it's used for anything else.
+func (c *apiClient) whoami(ctx context.Context) (*apiToken, error) {
+	var out apiToken
+	if err := c.do(ctx, http.MethodGet, "/v1/whoami", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) createCounter(ctx context.Context, ctr counter) error {
+	return c.do(ctx, http.MethodPost, "/v1/counters", ctr, nil)
+}
+
+func (c *apiClient) getCounter(ctx context.Context, name string) (*counter, error) {
+	var out counter
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/counters/%s", name), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) setCounterValue(ctx context.Context, name string, value int) error {
+	return c.do(ctx, http.MethodPut, apiPath("/v1/counters/%s/value", name), counterValueRequest{Value: value}, nil)
+}
+
+func (c *apiClient) deleteCounter(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("/v1/counters/%s", name), nil, nil)
+}
+
+func (c *apiClient) createCounterMember(ctx context.Context, m counterMember) error {
+	return c.do(ctx, http.MethodPost, "/v1/counter_members", m, nil)
+}
+
+func (c *apiClient) getCounterMember(ctx context.Context, id string) (*counterMember, error) {
+	var out counterMember
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/counter_members/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) deleteCounterMember(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("/v1/counter_members/%s", id), nil, nil)
+}
+
+func (c *apiClient) createSecret(ctx context.Context, sec secret) (*secret, error) {
+	var out secret
+	if err := c.do(ctx, http.MethodPost, "/v1/secrets", sec, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) getSecret(ctx context.Context, id string) (*secret, error) {
+	var out secret
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/secrets/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) setSecretValue(ctx context.Context, id, value string) error {
+	return c.do(ctx, http.MethodPut, apiPath("/v1/secrets/%s/value", id), secretValueRequest{Value: value}, nil)
+}
+
+func (c *apiClient) rotateSecretAPIKey(ctx context.Context, id string) (*secret, error) {
+	var out secret
+	if err := c.do(ctx, http.MethodPost, apiPath("/v1/secrets/%s/rotate_api_key", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) deleteSecret(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("/v1/secrets/%s", id), nil, nil)
+}
+
+func (c *apiClient) createParent(ctx context.Context, p parent) error {
+	return c.do(ctx, http.MethodPost, "/v1/parents", p, nil)
+}
+
+func (c *apiClient) getParent(ctx context.Context, id string) (*parent, error) {
+	var out parent
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/parents/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) updateParent(ctx context.Context, p parent) error {
+	return c.do(ctx, http.MethodPut, apiPath("/v1/parents/%s", p.ID), p, nil)
+}
+
+func (c *apiClient) deleteParent(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("/v1/parents/%s", id), nil, nil)
+}
+
+func (c *apiClient) createChild(ctx context.Context, ch child) error {
+	return c.do(ctx, http.MethodPost, "/v1/children", ch, nil)
+}
+
+func (c *apiClient) getChild(ctx context.Context, id string) (*child, error) {
+	var out child
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/children/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) updateChild(ctx context.Context, ch child) error {
+	return c.do(ctx, http.MethodPut, apiPath("/v1/children/%s", ch.ID), ch, nil)
+}
+
+func (c *apiClient) deleteChild(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("/v1/children/%s", id), nil, nil)
+}
+
+func (c *apiClient) createAllTypes(ctx context.Context, a allTypes) error {
+	return c.do(ctx, http.MethodPost, "/v1/all_types", a, nil)
+}
+
+func (c *apiClient) getAllTypes(ctx context.Context, id string) (*allTypes, error) {
+	var out allTypes
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/all_types/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) updateAllTypes(ctx context.Context, a allTypes) error {
+	return c.do(ctx, http.MethodPut, apiPath("/v1/all_types/%s", a.ID), a, nil)
+}
+
+func (c *apiClient) deleteAllTypes(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("/v1/all_types/%s", id), nil, nil)
+}
+
+func (c *apiClient) createExample(ctx context.Context, e example) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodPost, "/v1/examples", e, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) getExample(ctx context.Context, id string) (*example, error) {
+	var out example
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/examples/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) setExampleFields(ctx context.Context, id string, revision int, e example) (*operation, error) {
+	req := exampleFieldsRequest{
+		Revision:            revision,
+		NotComputedOptional: e.NotComputedOptional,
+		NotComputedRequired: e.NotComputedRequired,
+		Tags:                e.Tags,
+	}
+	var op operation
+	if err := c.do(ctx, http.MethodPut, apiPath("/v1/examples/%s/fields", id), req, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (c *apiClient) setExampleFoo(ctx context.Context, id string, revision int, foo []exampleFoo) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodPut, apiPath("/v1/examples/%s/foo", id), exampleFooRequest{Revision: revision, Foo: foo}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) patchExampleFoo(ctx context.Context, id string, revision int, ops []fooPatchOp) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodPatch, apiPath("/v1/examples/%s/foo", id), exampleFooPatchRequest{Revision: revision, Ops: ops}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) setExampleBaz(ctx context.Context, id string, revision int, baz []exampleBaz) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodPut, apiPath("/v1/examples/%s/baz", id), exampleBazRequest{Revision: revision, Baz: baz}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) setExampleSomeList(ctx context.Context, id string, revision int, items []string) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodPut, apiPath("/v1/examples/%s/some_list", id), exampleSomeListRequest{Revision: revision, SomeList: items}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) deleteExample(ctx context.Context, id string) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodDelete, apiPath("/v1/examples/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *apiClient) getOperation(ctx context.Context, id string) (*operation, error) {
+	var out operation
+	if err := c.do(ctx, http.MethodGet, apiPath("/v1/operations/%s", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,182 @@
+package mock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAttempt is a request the mock API server got, and how it answered.
+type testAttempt struct {
+	method         string
+	path           string
+	idempotencyKey string
+	status         int
+}
+
+// testAPIServer starts an HTTP server for api, and returns the requests it
+// gets.
+func testAPIServer(t *testing.T, api *apiServer) (*httptest.Server, func() []testAttempt) {
+	var mu sync.Mutex
+	var attempts []testAttempt
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, r)
+		mu.Lock()
+		attempts = append(attempts, testAttempt{r.Method, r.URL.Path, r.Header.Get(idempotencyKeyHeader), rec.Code})
+		mu.Unlock()
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []testAttempt {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]testAttempt{}, attempts...)
+	}
+}
+
+// testAPIClient returns a client of the server that retries according to
+// policy.
+func testAPIClient(server *httptest.Server, policy retryPolicy) *apiClient {
+	c := newAPIClient(server.URL, apiClientOptions{MaxAttempts: policy.MaxAttempts})
+	c.retry = policy
+	return c
+}
+
+func TestAPIClientRetryAfter(t *testing.T) {
+	ctx := context.Background()
+
+	// At 10 requests per second the next one is allowed 100ms after the
+	// first, but Retry-After is in whole seconds, so the server says 1.
+	for _, tc := range []struct {
+		name     string
+		maxWait  time.Duration
+		min, max time.Duration
+	}{
+		{"waited out", 5 * time.Second, time.Second, 2 * time.Second},
+		{"capped at MaxWait", 200 * time.Millisecond, 200 * time.Millisecond, 800 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, attempts := testAPIServer(t, newAPIServer(newBackend(""), apiServerOptions{RateLimit: 10}))
+			c := testAPIClient(server, retryPolicy{MaxAttempts: 2, MaxWait: tc.maxWait})
+
+			if err := c.createCounter(ctx, counter{Name: "limited"}); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			if _, err := c.getCounter(ctx, "limited"); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < tc.min || elapsed > tc.max {
+				t.Errorf("expected the retry to take between %s and %s, took %s", tc.min, tc.max, elapsed)
+			}
+
+			var statuses []int
+			for _, a := range attempts() {
+				statuses = append(statuses, a.status)
+			}
+			if len(statuses) != 3 || statuses[1] != http.StatusTooManyRequests || statuses[2] != http.StatusOK {
+				t.Errorf("expected the GET to be rate limited once and then succeed, got %v", statuses)
+			}
+		})
+	}
+}
+
+func TestAPIClientLostResponse(t *testing.T) {
+	// Every response to a change is lost, but the retry is answered with the
+	// response that was recorded for its idempotency key.
+	api := newAPIServer(newBackend(""), apiServerOptions{LostResponseRate: 1})
+	server, attempts := testAPIServer(t, api)
+	c := testAPIClient(server, retryPolicy{MaxAttempts: 3})
+
+	if err := c.createCounter(context.Background(), counter{Name: "once"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := attempts()
+	if len(got) != 2 || got[0].status != http.StatusGatewayTimeout || got[1].status != http.StatusCreated {
+		t.Fatalf("expected a lost response and a replayed one, got %+v", got)
+	}
+	if got[0].idempotencyKey == "" || got[0].idempotencyKey != got[1].idempotencyKey {
+		t.Errorf("expected both attempts to have the same idempotency key, got %q and %q", got[0].idempotencyKey, got[1].idempotencyKey)
+	}
+	err := api.backend.view(func(s *backendState) error {
+		if len(s.Counters) != 1 {
+			t.Errorf("expected exactly one counter, got %d", len(s.Counters))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIClientGivesUp(t *testing.T) {
+	server, attempts := testAPIServer(t, newAPIServer(newBackend(""), apiServerOptions{ErrorRate: 1}))
+
+	c := testAPIClient(server, retryPolicy{MaxAttempts: 3, MaxWait: time.Millisecond})
+	_, err := c.getCounter(context.Background(), "unavailable")
+	if err == nil || !strings.HasPrefix(err.Error(), "giving up after 3 attempts: ") || !strings.Contains(err.Error(), "temporarily unavailable") {
+		t.Errorf("expected to give up after 3 attempts, got %v", err)
+	}
+	if got := len(attempts()); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+
+	// A single attempt isn't worth mentioning.
+	c = testAPIClient(server, retryPolicy{MaxAttempts: 1})
+	_, err = c.getCounter(context.Background(), "unavailable")
+	if err == nil || strings.Contains(err.Error(), "giving up") {
+		t.Errorf("expected the error of the only attempt, got %v", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{MinWait: 100 * time.Millisecond, MaxWait: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		40: time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			if got := p.backoff(attempt); got < want/2 || got > want {
+				t.Errorf("expected the wait after attempt %d to be between %s and %s, got %s", attempt, want/2, want, got)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-5", 0, false},
+		{"Sat, 01 Jan 2022 12:00:30 GMT", 30 * time.Second, true},
+		// A date that has passed means there's no need to wait.
+		{"Sat, 01 Jan 2022 11:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	} {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, expected %s, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,749 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contractTokens are the tokens and OAuth2 client the server is given, so
+// that the checks can make requests that are allowed and requests that
+// aren't.
+var contractTokens = apiTokensFile{
+	Tokens: []apiToken{
+		{Name: "contract-read-write", Token: "contract-read-write-token", Scope: tokenScopeReadWrite},
+		{Name: "contract-read-only", Token: "contract-read-only-token", Scope: tokenScopeReadOnly},
+	},
+	Clients: []oauthClient{
+		{ClientID: "contract-client", ClientSecret: "contract-client-secret", Scope: tokenScopeReadWrite},
+	},
+}
+
+// TestContract checks that the provider's API client (see api_client.go)
+// and the mock API server still agree about what goes over the wire.
+//
+// It starts a server, has the client make every call it knows how to make
+// (including ones that are meant to fail), and checks every request and
+// response against the server's own OpenAPI document (see api_openapi.go):
+// that the path and method are ones the server declares, that the request
+// body is what the route expects, and that the status and body of the
+// response, errors included, are what the route says it returns.
+//
+// The client and the server share the types in api.go, so most of what could
+// go wrong is something like the client calling a path the server no longer
+// has, sending a whole object where the server now wants part of one, or the
+// server returning a status the client doesn't expect. Those mistakes don't
+// stop anything compiling, and without this they'd only turn up when a CRUD
+// function failed, a long way from their cause.
+func TestContract(t *testing.T) {
+	s := newAPIServer(newBackend(""), apiServerOptions{})
+	s.auth = &apiAuth{
+		tokens:   map[string]*apiToken{},
+		clients:  map[string]*oauthClient{},
+		lifetime: time.Minute,
+		issued:   map[string]*issuedToken{},
+	}
+	for i := range contractTokens.Tokens {
+		t := contractTokens.Tokens[i]
+		s.auth.tokens[t.Token] = &t
+	}
+	for i := range contractTokens.Clients {
+		c := contractTokens.Clients[i]
+		s.auth.clients[c.ClientID] = &c
+	}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	// The document is checked against as the JSON it's published as, rather
+	// than the Go values it's built from, since that's what anyone
+	// generating a client from it sees.
+	doc, err := json.Marshal(openAPIDocument(s.routes, server.URL))
+	if err != nil {
+		t.Fatalf("failed to encode the OpenAPI document: %s", err)
+	}
+	spec, err := decodeJSON(doc)
+	if err != nil {
+		t.Fatalf("failed to decode the OpenAPI document: %s", err)
+	}
+
+	checker := &contractChecker{spec: spec.(map[string]any), covered: map[string]bool{}}
+	newClient := func(opts apiClientOptions, checkRequests bool) *apiClient {
+		opts.MaxAttempts = 1
+		c := newAPIClient(server.URL, opts)
+		c.http.Transport = &contractTransport{next: c.http.Transport, checker: checker, checkRequests: checkRequests}
+		return c
+	}
+	readWrite := apiClientOptions{Token: contractTokens.Tokens[0].Token}
+	clients := contractClients{
+		readWrite: newClient(readWrite, true),
+		readOnly:  newClient(apiClientOptions{Token: contractTokens.Tokens[1].Token}, true),
+		anonymous: newClient(apiClientOptions{}, true),
+		oauth:     newClient(apiClientOptions{ClientID: contractTokens.Clients[0].ClientID, ClientSecret: contractTokens.Clients[0].ClientSecret}, true),
+		badOAuth:  newClient(apiClientOptions{ClientID: contractTokens.Clients[0].ClientID, ClientSecret: "wrong"}, true),
+		invalid:   newClient(readWrite, false),
+	}
+
+	// NOTE: The steps share the server and run in order, as later steps use
+	// the objects created by earlier ones, so a failed step doesn't stop the
+	// ones after it.
+	ctx := context.Background()
+	for _, step := range contractSteps(clients) {
+		step := step
+		t.Run(step.name, func(t *testing.T) {
+			checker.t = t
+			if err := step.run(ctx); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	checker.t = t
+
+	// Every operation has to be called by at least one step, so that a new
+	// route can't be added without being checked.
+	for _, id := range checker.operationIDs() {
+		if !checker.covered[id] {
+			checker.fail("operation %s isn't called by any of the checks", id)
+		}
+	}
+	t.Logf("checked %d requests covering %d operations", checker.exchanges, len(checker.covered))
+}
+
+// contractClients are the clients the checks make requests with.
+type contractClients struct {
+	readWrite *apiClient
+	readOnly  *apiClient
+	anonymous *apiClient
+	oauth     *apiClient
+	badOAuth  *apiClient
+
+	// invalid sends requests that break the contract on purpose, to check
+	// the server's responses to them. Only its responses are checked.
+	invalid *apiClient
+}
+
+// contractStep is one of the checks: a few calls that make sense together,
+// e.g. creating an object and reading it back. It returns an error if a
+// call doesn't do what it should, which is reported along with any
+// contract violations.
+type contractStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// contractSteps returns the checks, in the order they're run. Later steps
+// use the objects created by earlier ones.
+func contractSteps(c contractClients) []contractStep {
+	rw := c.readWrite
+
+	return []contractStep{
+		{"whoami", func(ctx context.Context) error {
+			t, err := rw.whoami(ctx)
+			if err != nil {
+				return err
+			}
+			return expectEqual("token name", t.Name, contractTokens.Tokens[0].Name)
+		}},
+		{"whoami without a token", func(ctx context.Context) error {
+			_, err := c.anonymous.whoami(ctx)
+			return expectCode(err, apiErrorUnauthorized)
+		}},
+		{"whoami with an OAuth2 client", func(ctx context.Context) error {
+			t, err := c.oauth.whoami(ctx)
+			if err != nil {
+				return err
+			}
+			return expectEqual("token name", t.Name, contractTokens.Clients[0].ClientID)
+		}},
+		{"whoami with the wrong client secret", func(ctx context.Context) error {
+			_, err := c.badOAuth.whoami(ctx)
+			var e *oauthError
+			if !errors.As(err, &e) || e.Code != "invalid_client" {
+				return fmt.Errorf("expected an invalid_client error, got: %v", err)
+			}
+			return nil
+		}},
+
+		{"counters", func(ctx context.Context) error {
+			if err := rw.createCounter(ctx, counter{Name: "contract", Value: 1}); err != nil {
+				return err
+			}
+			if err := expectError(rw.createCounter(ctx, counter{Name: "contract"}), errAlreadyExists); err != nil {
+				return err
+			}
+			if err := rw.setCounterValue(ctx, "contract", 2); err != nil {
+				return err
+			}
+			got, err := rw.getCounter(ctx, "contract")
+			if err != nil {
+				return err
+			}
+			return expectEqual("counter", *got, counter{Name: "contract", Value: 2})
+		}},
+		{"counter members", func(ctx context.Context) error {
+			m := counterMember{ID: "contract-member", Counter: "contract", Slot: 1}
+			if err := rw.createCounterMember(ctx, m); err != nil {
+				return err
+			}
+			got, err := rw.getCounterMember(ctx, m.ID)
+			if err != nil {
+				return err
+			}
+			if err := expectEqual("counter member", *got, m); err != nil {
+				return err
+			}
+			if err := expectError(rw.deleteCounter(ctx, "contract"), errConflict); err != nil {
+				return err
+			}
+			if err := rw.deleteCounterMember(ctx, m.ID); err != nil {
+				return err
+			}
+			_, err = rw.getCounterMember(ctx, m.ID)
+			return expectError(err, errNotFound)
+		}},
+		{"delete a counter with a read-only token", func(ctx context.Context) error {
+			return expectCode(c.readOnly.deleteCounter(ctx, "contract"), apiErrorForbidden)
+		}},
+		{"delete a counter", func(ctx context.Context) error {
+			if err := rw.deleteCounter(ctx, "contract"); err != nil {
+				return err
+			}
+			_, err := rw.getCounter(ctx, "contract")
+			return expectError(err, errNotFound)
+		}},
+
+		{"secrets", func(ctx context.Context) error {
+			created, err := rw.createSecret(ctx, secret{ID: "contract-secret", Name: "contract", Value: "hunter2"})
+			if err != nil {
+				return err
+			}
+			if created.APIKey == "" {
+				return fmt.Errorf("the created secret has no api_key")
+			}
+			if err := rw.setSecretValue(ctx, created.ID, "hunter3"); err != nil {
+				return err
+			}
+			rotated, err := rw.rotateSecretAPIKey(ctx, created.ID)
+			if err != nil {
+				return err
+			}
+			if rotated.APIKey == created.APIKey {
+				return fmt.Errorf("rotating the api_key didn't change it")
+			}
+			got, err := rw.getSecret(ctx, created.ID)
+			if err != nil {
+				return err
+			}
+			if got.Value != "" || got.ValueHash == "" {
+				return fmt.Errorf("reading a secret should return the hash of its value and not the value")
+			}
+			return rw.deleteSecret(ctx, created.ID)
+		}},
+
+		{"parents and children", func(ctx context.Context) error {
+			p := parent{ID: "contract-parent", Name: "contract"}
+			if err := rw.createParent(ctx, p); err != nil {
+				return err
+			}
+			ch := child{ID: "contract-child", ParentID: "no-such-parent", Name: "contract"}
+			if err := expectError(rw.createChild(ctx, ch), errInvalidParent); err != nil {
+				return err
+			}
+			ch.ParentID = p.ID
+			if err := rw.createChild(ctx, ch); err != nil {
+				return err
+			}
+			p.Name, ch.Name = "contract-renamed", "contract-renamed"
+			if err := rw.updateParent(ctx, p); err != nil {
+				return err
+			}
+			if err := rw.updateChild(ctx, ch); err != nil {
+				return err
+			}
+			gotParent, err := rw.getParent(ctx, p.ID)
+			if err != nil {
+				return err
+			}
+			if err := expectEqual("parent", *gotParent, p); err != nil {
+				return err
+			}
+			gotChild, err := rw.getChild(ctx, ch.ID)
+			if err != nil {
+				return err
+			}
+			if err := expectEqual("child", *gotChild, ch); err != nil {
+				return err
+			}
+			if err := expectError(rw.deleteParent(ctx, p.ID), errConflict); err != nil {
+				return err
+			}
+			if err := rw.deleteChild(ctx, ch.ID); err != nil {
+				return err
+			}
+			return rw.deleteParent(ctx, p.ID)
+		}},
+
+		{"all types", func(ctx context.Context) error {
+			a := allTypes{
+				ID:               "contract-all-types",
+				BoolValue:        true,
+				FloatValue:       1.5,
+				IntWithDefault:   3,
+				StringValue:      "a",
+				StringMap:        map[string]string{"a": "b"},
+				IntMap:           map[string]int{"a": 1},
+				FloatMap:         map[string]float64{"a": 0.5},
+				BoolMap:          map[string]bool{"a": true},
+				StringSet:        []string{"a", "b"},
+				IntSet:           []int{1, 2},
+				BlockSet:         []allTypesKeyValue{{Key: "a", Value: "b"}},
+				Nested:           []allTypesNested{{Name: "a", LevelTwo: []allTypesLevelTwo{{Name: "b", LevelThree: []allTypesLevelThree{{Value: "c"}}}}}},
+				OptionalComputed: "a",
+				ConflictsA:       "a",
+				ExactlyOneA:      "a",
+				AtLeastOneA:      "a",
+				RequiredWithA:    "a",
+				RequiredWithB:    "b",
+				LimitedList:      []string{"a"},
+				DeprecatedValue:  "a",
+			}
+			if err := rw.createAllTypes(ctx, a); err != nil {
+				return err
+			}
+			got, err := rw.getAllTypes(ctx, a.ID)
+			if err != nil {
+				return err
+			}
+			if err := expectEqual("all_types", *got, a); err != nil {
+				return err
+			}
+			a.StringValue, a.IntSet = "b", nil
+			if err := rw.updateAllTypes(ctx, a); err != nil {
+				return err
+			}
+			return rw.deleteAllTypes(ctx, a.ID)
+		}},
+
+		{"examples", func(ctx context.Context) error {
+			op, err := rw.createExample(ctx, example{
+				Name:                "contract",
+				NotComputedRequired: "a",
+				Foo:                 []exampleFoo{{Key: "a", Bar: []exampleBar{{}}}},
+				Baz:                 []exampleBaz{{Qux: "a"}},
+				SomeList:            []string{"a"},
+				Tags:                map[string]string{"a": "b"},
+			})
+			if err != nil {
+				return err
+			}
+			if op, err = rw.getOperation(ctx, op.ID); err != nil {
+				return err
+			}
+			if err := expectEqual("operation status", op.Status, operationDone); err != nil {
+				return err
+			}
+			id := op.Target
+
+			// Each change is made to the revision the one before it made.
+			changes := []func(revision int) (*operation, error){
+				func(revision int) (*operation, error) {
+					return rw.setExampleFields(ctx, id, revision, example{NotComputedRequired: "b", Tags: map[string]string{"c": "d"}})
+				},
+				func(revision int) (*operation, error) {
+					return rw.setExampleFoo(ctx, id, revision, []exampleFoo{{Key: "b", Bar: []exampleBar{{}}}})
+				},
+				func(revision int) (*operation, error) {
+					return rw.patchExampleFoo(ctx, id, revision, []fooPatchOp{{Op: "add", Index: 1, Value: &exampleFoo{Key: "c"}}})
+				},
+				func(revision int) (*operation, error) {
+					return rw.setExampleBaz(ctx, id, revision, []exampleBaz{{Qux: "b"}})
+				},
+				func(revision int) (*operation, error) {
+					return rw.setExampleSomeList(ctx, id, revision, nil)
+				},
+			}
+			for _, change := range changes {
+				e, err := rw.getExample(ctx, id)
+				if err != nil {
+					return err
+				}
+				if _, err := change(e.Revision); err != nil {
+					return err
+				}
+			}
+
+			if _, err := rw.setExampleBaz(ctx, id, 1, nil); err != nil {
+				if err := expectError(err, errConflict); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("a change to an old revision of an example was accepted")
+			}
+			if _, err := rw.deleteExample(ctx, id); err != nil {
+				return err
+			}
+			_, err = rw.getExample(ctx, id)
+			return expectError(err, errNotFound)
+		}},
+
+		// The server's own errors. The client never makes these requests on
+		// purpose, but it has to understand the responses if it does.
+		{"unknown path", func(ctx context.Context) error {
+			return expectCode(c.invalid.do(ctx, http.MethodGet, "/v1/no_such_path", nil, nil), apiErrorNoSuchPath)
+		}},
+		{"method not allowed", func(ctx context.Context) error {
+			return expectCode(c.invalid.do(ctx, http.MethodPatch, "/v1/counters/contract", counterValueRequest{}, nil), apiErrorMethodNotAllowed)
+		}},
+		{"invalid request body", func(ctx context.Context) error {
+			return expectCode(c.invalid.do(ctx, http.MethodPost, "/v1/counters", json.RawMessage(`"not an object"`), nil), apiErrorBadRequest)
+		}},
+	}
+}
+
+func expectError(err, target error) error {
+	if !errors.Is(err, target) {
+		return fmt.Errorf("expected a %q error, got: %v", target, err)
+	}
+	return nil
+}
+
+func expectCode(err error, code string) error {
+	var e *apiError
+	if !errors.As(err, &e) || e.Code != code {
+		return fmt.Errorf("expected a %q error, got: %v", code, err)
+	}
+	return nil
+}
+
+func expectEqual(what string, got, want any) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s is %+v, expected %+v", what, got, want)
+	}
+	return nil
+}
+
+// contractTransport hands every request and response to the checker on
+// their way through.
+type contractTransport struct {
+	next          http.RoundTripper
+	checker       *contractChecker
+	checkRequests bool
+}
+
+func (t *contractTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.checker.check(req, reqBody, resp, respBody, t.checkRequests)
+	return resp, nil
+}
+
+// contractChecker checks requests and responses against an OpenAPI document.
+type contractChecker struct {
+	spec map[string]any
+
+	// t is the test of the step that's running, which contract violations
+	// are reported to.
+	t *testing.T
+
+	exchanges int
+	covered   map[string]bool
+}
+
+func (c *contractChecker) fail(format string, args ...any) {
+	c.t.Helper()
+	c.t.Errorf(format, args...)
+}
+
+// check checks one request (if checkRequest is true) and the response to it.
+func (c *contractChecker) check(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, checkRequest bool) {
+	c.exchanges++
+	exchange := fmt.Sprintf("%s %s: %d", req.Method, req.URL.Path, resp.StatusCode)
+	c.t.Log(exchange)
+
+	op, ok := c.operation(req.Method, req.URL.EscapedPath())
+	if !ok {
+		// The server should agree that there's no such route.
+		if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusMethodNotAllowed {
+			c.fail("%s: the document has no %s %s, but the server didn't reject it", exchange, req.Method, req.URL.Path)
+		}
+		c.checkResponse(exchange, map[string]any{}, resp, respBody)
+		return
+	}
+	id, _ := op["operationId"].(string)
+	c.covered[id] = true
+
+	if rb, ok := op["requestBody"].(map[string]any); ok && checkRequest {
+		content, _ := rb["content"].(map[string]any)
+		c.checkBody(exchange+": request", content, req.Header.Get("Content-Type"), reqBody)
+	} else if !ok && checkRequest && len(reqBody) > 0 {
+		c.fail("%s: %s doesn't take a request body, but was sent one", exchange, id)
+	}
+	c.checkResponse(exchange, op, resp, respBody)
+}
+
+// checkResponse checks a response against the responses an operation
+// declares. An error status that isn't declared falls back to the
+// operation's default response, but a successful one doesn't: the client
+// has to know about every way a call can succeed.
+func (c *contractChecker) checkResponse(exchange string, op map[string]any, resp *http.Response, respBody []byte) {
+	responses, _ := op["responses"].(map[string]any)
+	declared, ok := responses[fmt.Sprint(resp.StatusCode)].(map[string]any)
+	if !ok && resp.StatusCode >= http.StatusBadRequest {
+		declared, ok = responses["default"].(map[string]any)
+		if !ok {
+			// A request for a path that isn't in the document still has to
+			// get one of the server's usual errors.
+			declared, ok = map[string]any{"$ref": "#/components/responses/Error"}, true
+		}
+	}
+	if !ok {
+		c.fail("%s: the status isn't one the operation declares", exchange)
+		return
+	}
+	declared = c.resolve(declared)
+
+	content, _ := declared["content"].(map[string]any)
+	if content == nil {
+		if len(respBody) > 0 {
+			c.fail("%s: the response shouldn't have a body, but has %q", exchange, respBody)
+		}
+		return
+	}
+	c.checkBody(exchange+": response", content, resp.Header.Get("Content-Type"), respBody)
+}
+
+// checkBody checks a body against the media types it's allowed to be.
+func (c *contractChecker) checkBody(what string, content map[string]any, contentType string, body []byte) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		c.fail("%s: the content type is %q, which should be one of %s", what, contentType, strings.Join(sortedKeysOf(content), ", "))
+		return
+	}
+	schema, _ := media["schema"].(map[string]any)
+
+	var v any
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			c.fail("%s: invalid form: %s", what, err)
+			return
+		}
+		m := map[string]any{}
+		for k := range form {
+			m[k] = form.Get(k)
+		}
+		v = m
+	default:
+		var err error
+		if v, err = decodeJSON(body); err != nil {
+			c.fail("%s: invalid JSON: %s", what, err)
+			return
+		}
+	}
+
+	for _, problem := range c.validate(schema, v, "body") {
+		c.fail("%s: %s", what, problem)
+	}
+}
+
+// validate checks a JSON value against a schema, returning what's wrong with
+// it. It understands the parts of JSON Schema the OpenAPI document uses.
+//
+// NOTE: It's stricter than OpenAPI in one way: an object may only have the
+// properties its schema lists (unless the schema has additionalProperties).
+// A property the other side doesn't know about is exactly the kind of drift
+// these checks are meant to catch.
+func (c *contractChecker) validate(schema map[string]any, v any, path string) []string {
+	schema = c.resolve(schema)
+
+	if v == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		if len(schema) == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s is null", path)}
+	}
+
+	var problems []string
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, s := range allOf {
+			sub, _ := s.(map[string]any)
+			problems = append(problems, c.validate(sub, v, path)...)
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s is %v, which isn't one of %v", path, v, enum))
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return append(problems, fmt.Sprintf("%s should be an object", path))
+		}
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			if _, ok := obj[r.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s has no %q", path, r))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		additional, hasAdditional := schema["additionalProperties"].(map[string]any)
+		for _, k := range sortedKeysOf(obj) {
+			if p, ok := properties[k].(map[string]any); ok {
+				problems = append(problems, c.validate(p, obj[k], path+"."+k)...)
+			} else if hasAdditional {
+				problems = append(problems, c.validate(additional, obj[k], path+"."+k)...)
+			} else {
+				problems = append(problems, fmt.Sprintf("%s has %q, which its schema doesn't", path, k))
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return append(problems, fmt.Sprintf("%s should be an array", path))
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range arr {
+			problems = append(problems, c.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return append(problems, fmt.Sprintf("%s should be a string", path))
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s should be a date-time: %s", path, err))
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			return append(problems, fmt.Sprintf("%s should be an integer", path))
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return append(problems, fmt.Sprintf("%s should be a number", path))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return append(problems, fmt.Sprintf("%s should be a boolean", path))
+		}
+	}
+	return problems
+}
+
+// operation finds the operation for a request in the document.
+func (c *contractChecker) operation(method, escapedPath string) (map[string]any, bool) {
+	paths, _ := c.spec["paths"].(map[string]any)
+	for _, pattern := range sortedKeysOf(paths) {
+		if _, ok := matchPath(pattern, escapedPath); !ok {
+			continue
+		}
+		item, _ := paths[pattern].(map[string]any)
+		op, ok := item[strings.ToLower(method)].(map[string]any)
+		return op, ok
+	}
+	return nil, false
+}
+
+// operationIDs returns the IDs of every operation in the document.
+func (c *contractChecker) operationIDs() []string {
+	var ids []string
+	paths, _ := c.spec["paths"].(map[string]any)
+	for _, item := range paths {
+		for _, op := range item.(map[string]any) {
+			if id, ok := op.(map[string]any)["operationId"].(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// resolve follows a $ref to the part of the document it refers to.
+func (c *contractChecker) resolve(v map[string]any) map[string]any {
+	for {
+		ref, ok := v["$ref"].(string)
+		if !ok {
+			return v
+		}
+		var target any = c.spec
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			m, _ := target.(map[string]any)
+			target = m[part]
+		}
+		if v, ok = target.(map[string]any); !ok {
+			return map[string]any{}
+		}
+	}
+}
+
+// decodeJSON decodes JSON without turning numbers into float64, so that an
+// integer can be told apart from a number.
+func decodeJSON(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+func sortedKeysOf(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
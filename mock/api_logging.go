@@ -0,0 +1,118 @@
+package mock
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/logging"
+)
+
+// newLoggingTransport logs every request the apiClient makes, and the
+// response it gets, at DEBUG level. Run terraform with TF_LOG=DEBUG (or
+// TF_LOG_PROVIDER=DEBUG for just the provider's logs) to see them.
+//
+// The logging itself is done by the SDK's logging.NewLoggingHTTPTransport,
+// which logs the method, URL, headers and body of the request, and the
+// status, headers and body of the response. All we add is the secrets that
+// tflog should mask (see maskSecrets), and how long the request took, which
+// the SDK's transport doesn't log.
+//
+// NOTE: tflog writes to the logger that the SDK attaches to the context of
+// each CRUD function, so a request is only logged if it was made with that
+// context (which is why every backendAPI method takes one).
+func newLoggingTransport(next http.RoundTripper) http.RoundTripper {
+	return &maskingTransport{next: logging.NewLoggingHTTPTransport(next)}
+}
+
+// maskingTransport adds the masks of maskSecrets to the context of each
+// request, before the SDK's transport logs it.
+type maskingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *maskingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := maskSecrets(req.Context())
+	fields := map[string]any{
+		logging.FieldHttpRequestMethod: req.Method,
+		logging.FieldHttpRequestUri:    req.URL.RequestURI(),
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	fields["http_duration_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		// The SDK's transport doesn't log a request that never got a
+		// response.
+		fields["error"] = err.Error()
+		tflog.Debug(ctx, "Mock API request failed", fields)
+		return nil, err
+	}
+	fields[logging.FieldHttpResponseStatusCode] = resp.StatusCode
+	tflog.Debug(ctx, "Mock API request finished", fields)
+	return resp, nil
+}
+
+// redacted replaces sensitive values, in the logs and in cassettes. It's
+// what tflog masks them with too.
+const redacted = "***"
+
+// sensitiveHeaders are the headers whose values are never logged, or
+// recorded in a cassette.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// sensitiveFields are the JSON and form fields whose values are never
+// logged, or recorded in a cassette, wherever they appear in a body.
+//
+// NOTE: 'value' is the value of a secret, but other objects have fields
+// called 'value' too, and those are redacted as well. That's only a problem
+// when debugging something to do with one of them, whereas a secret in a log
+// file (or a support ticket) can't be taken back.
+var sensitiveFields = []string{
+	"value",
+	"api_key",
+	"token",
+	"access_token",
+	"refresh_token",
+	"client_secret",
+	"password",
+}
+
+// sensitiveFieldPatterns match the values of sensitiveFields in a body: the
+// first group is whatever comes before the value, and the second whatever
+// comes after it.
+//
+// Only non-empty strings are matched, so that a number (e.g. the 'value' of
+// a counter) is left alone, and the logs still show whether a value was
+// sent. The form pattern covers the token endpoint, which takes a form (see
+// api_oauth.go), and query strings.
+var sensitiveFieldPatterns = func() []*regexp.Regexp {
+	fields := strings.Join(sensitiveFields, "|")
+	return []*regexp.Regexp{
+		regexp.MustCompile(`("(?:` + fields + `)"\s*:\s*")(?:[^"\\]|\\.)+(")`),
+		regexp.MustCompile(`(\b(?:` + fields + `)=)[^&\s]+()`),
+	}
+}()
+
+// maskSecrets returns a context whose logger masks the values of
+// sensitiveHeaders and sensitiveFields.
+//
+// NOTE: tflog masks the whole match of a pattern, so a masked field shows up
+// as `***` in place of `"api_key":"..."`, name and all. redactBody (for
+// cassettes) keeps the name, as it has to leave valid JSON behind.
+func maskSecrets(ctx context.Context) context.Context {
+	ctx = tflog.MaskFieldValuesWithFieldKeys(ctx, sensitiveHeaders...)
+	return tflog.MaskAllFieldValuesRegexes(ctx, sensitiveFieldPatterns...)
+}
+
+// redactBody returns a body with the values of sensitiveFields replaced.
+func redactBody(body []byte) string {
+	s := string(body)
+	for _, re := range sensitiveFieldPatterns {
+		s = re.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestLoggingTransportMasksSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"a","api_key":"response-secret","value":12}`)
+	}))
+	defer srv.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/secrets", strings.NewReader(`{"name":"a","value":"request-secret","api_key":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer header-secret")
+	resp, err := (&http.Client{Transport: newLoggingTransport(http.DefaultTransport)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "response-secret") {
+		t.Errorf("the response body was changed: %s", body)
+	}
+
+	logged := output.String()
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected the request, the response and the duration to be logged, got %d entries: %s", len(entries), logged)
+	}
+	for _, secret := range []string{"header-secret", "request-secret", "response-secret", "cookie-secret"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("%s was logged: %s", secret, logged)
+		}
+	}
+	// Empty strings and numbers aren't secrets.
+	for _, kept := range []string{`\"api_key\":\"\"`, `\"value\":12`} {
+		if !strings.Contains(logged, kept) {
+			t.Errorf("expected %s to be logged: %s", kept, logged)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	cases := map[string]string{
+		`{"name":"a","value":"secret"}`:                                    `{"name":"a","value":"***"}`,
+		`{"value": "with \"quotes\"", "id":"a"}`:                           `{"value": "***", "id":"a"}`,
+		`{"value":12,"api_key":""}`:                                        `{"value":12,"api_key":""}`,
+		`[{"nested":{"token":"secret"}}]`:                                  `[{"nested":{"token":"***"}}]`,
+		`grant_type=client_credentials&client_id=a&client_secret=s%26cret`: `grant_type=client_credentials&client_id=a&client_secret=***`,
+	}
+	for body, want := range cases {
+		if got := redactBody([]byte(body)); got != want {
+			t.Errorf("redactBody(%s) = %s, want %s", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthTokenSource gets access tokens for the apiClient from the mock API
+// server's token endpoint (see issueToken in api_auth.go), using the
+// provider's 'client_id' and 'client_secret', and caches them until shortly
+// before they expire.
+//
+// Access tokens are short-lived, so one can easily expire in the middle of a
+// long apply. There are two ways the client finds out that it needs a new one:
+//
+//   - The token is close to the expiry time the server gave it. It's
+//     refreshed a little early (see get), so that it doesn't expire while a
+//     request is on its way to the server.
+//   - The server rejects it with a 401 anyway, e.g. because the server was
+//     restarted and forgot every token it issued. The request is then sent
+//     again, once, with a new token (see apiClient.send).
+type oauthTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	http         *http.Client
+
+	// NOTE: terraform makes up to 10 calls at once (see its -parallelism
+	// flag), and they all need a token. mu is held while a token is fetched,
+	// so that when the token expires only one of them fetches a new one and
+	// the others wait for it, rather than each of them fetching their own.
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+}
+
+// oauthError is an error response from the token endpoint.
+type oauthError struct {
+	Status      int
+	Code        string
+	Description string
+}
+
+func (e *oauthError) Error() string {
+	return fmt.Sprintf("failed to get an access token: %s: %s (HTTP %d)", e.Code, e.Description, e.Status)
+}
+
+// get returns a token that hasn't expired, fetching a new one if need be.
+func (s *oauthTokenSource) get(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.refresh) {
+		return s.token, nil
+	}
+
+	resp, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The token is refreshed when 90% of its lifetime is up. A fixed margin
+	// (e.g. a minute) would be more usual, but it would be longer than the
+	// whole lifetime of the very short-lived tokens used for testing.
+	lifetime := time.Duration(resp.ExpiresIn) * time.Second
+	s.token = resp.AccessToken
+	s.refresh = time.Now().Add(lifetime - lifetime/10)
+	log.Printf(">>> got an access token for client %q, which expires in %s", s.clientID, lifetime)
+
+	return s.token, nil
+}
+
+// invalidate forgets token, so that the next call to get fetches a new one.
+//
+// NOTE: It's only forgotten if it's still the current token. When several
+// requests are rejected with the same expired token, the first one to get
+// here causes a new token to be fetched, and the others mustn't throw that
+// new token away again.
+func (s *oauthTokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// fetch asks the token endpoint for a new token.
+func (s *oauthTokenSource) fetch(ctx context.Context) (*oauthTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "terraform-provider-mock")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", s.tokenURL, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthResp oauthErrorResponse
+		if err := json.Unmarshal(body, &oauthResp); err == nil && oauthResp.Error != "" {
+			return nil, &oauthError{Status: resp.StatusCode, Code: oauthResp.Error, Description: oauthResp.ErrorDescription}
+		}
+
+		// Not every error comes from the token endpoint itself, e.g. the
+		// server's rate limiter answers in the API's own format.
+		e := &oauthError{Status: resp.StatusCode, Code: apiErrorInternal, Description: strings.TrimSpace(string(body))}
+		var apiResp apiErrorResponse
+		if err := json.Unmarshal(body, &apiResp); err == nil && apiResp.Error != nil {
+			e.Code, e.Description = apiResp.Error.Code, apiResp.Error.Message
+		}
+		return nil, e
+	}
+
+	var out oauthTokenResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %w", s.tokenURL, err)
+	}
+	if out.AccessToken == "" || !strings.EqualFold(out.TokenType, "Bearer") {
+		return nil, fmt.Errorf("the token endpoint returned a %q token, where a bearer token was expected", out.TokenType)
+	}
+	return &out, nil
+}
@@ -0,0 +1,125 @@
+package mock
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testOAuthAPIServer starts the mock API server with an OAuth2 client whose
+// tokens last for lifetime, and returns an apiClient using that client.
+func testOAuthAPIServer(t *testing.T, lifetime time.Duration) (*apiServer, *apiClient, func() []testAttempt) {
+	api := newAPIServer(newBackend(""), apiServerOptions{})
+	api.auth = &apiAuth{
+		tokens: map[string]*apiToken{},
+		clients: map[string]*oauthClient{
+			"deployer": {ClientID: "deployer", ClientSecret: "secret", Scope: tokenScopeReadWrite},
+		},
+		lifetime: lifetime,
+		issued:   map[string]*issuedToken{},
+	}
+	server, attempts := testAPIServer(t, api)
+
+	c := newAPIClient(server.URL, apiClientOptions{MaxAttempts: 1, ClientID: "deployer", ClientSecret: "secret"})
+	return api, c, attempts
+}
+
+// testCountAttempts counts the requests to path.
+func testCountAttempts(attempts []testAttempt, path string) int {
+	var n int
+	for _, a := range attempts {
+		if a.path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestOAuthTokenSourceExpiry(t *testing.T) {
+	_, c, attempts := testOAuthAPIServer(t, time.Second)
+	ctx := context.Background()
+	if err := c.createCounter(ctx, counter{Name: "parallel"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Like terraform with its default -parallelism, for long enough that the
+	// token has to be refreshed twice. Only one call should fetch each new
+	// token, and none of them should ever use an expired one.
+	const parallelism = 10
+	deadline := time.Now().Add(2500 * time.Millisecond)
+	var wg sync.WaitGroup
+	errs := make(chan error, parallelism)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				if _, err := c.getCounter(ctx, "parallel"); err != nil {
+					errs <- err
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// A token lasting a second is refreshed after 900ms, so 2.5s takes 3.
+	if n := testCountAttempts(attempts(), oauthTokenPath); n < 3 || n > 4 {
+		t.Errorf("expected 3 or 4 tokens to be fetched, got %d", n)
+	}
+	for _, a := range attempts() {
+		if a.status == http.StatusUnauthorized {
+			t.Errorf("expected no request to be rejected, got %+v", a)
+		}
+	}
+}
+
+func TestAPIClientRejectedToken(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retried with a new token", func(t *testing.T) {
+		api, c, attempts := testOAuthAPIServer(t, time.Hour)
+		if err := c.createCounter(ctx, counter{Name: "restarted"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Restarting the server forgets every token it issued, long before
+		// the client thinks they expire.
+		api.auth.mu.Lock()
+		api.auth.issued = map[string]*issuedToken{}
+		api.auth.mu.Unlock()
+
+		if _, err := c.getCounter(ctx, "restarted"); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, a := range attempts()[2:] {
+			got = append(got, a.method+" "+a.path+" "+http.StatusText(a.status))
+		}
+		want := []string{"GET /v1/counters/restarted Unauthorized", "POST " + oauthTokenPath + " OK", "GET /v1/counters/restarted OK"}
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("rejected again", func(t *testing.T) {
+		// Every token has expired by the time it's used, so the new token
+		// is rejected too, and that's the end of it.
+		_, c, attempts := testOAuthAPIServer(t, 0)
+		_, err := c.getCounter(ctx, "expired")
+		if err == nil || !strings.Contains(err.Error(), "the access token expired") {
+			t.Errorf("expected the access token to be rejected, got %v", err)
+		}
+		if n := testCountAttempts(attempts(), "/v1/counters/expired"); n != 2 {
+			t.Errorf("expected the request to be sent twice, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,363 @@
+package mock
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The mock API server describes itself with an OpenAPI 3 document, which is
+// served at /openapi.json and printed by `terraform-provider-mock openapi`.
+// It can be used to generate a client for the API in another language (e.g.
+// to set up test fixtures), or to check that a request matches what the
+// server expects.
+//
+// The document isn't written by hand. The paths come from the route table
+// (see apiRouteDoc in api_routes.go), and the schemas from the Go types that
+// go over the wire, so it can't drift from what the server actually does.
+
+// openAPIPath is where the server publishes its OpenAPI document. Like the
+// token endpoint it isn't under /v1, and it doesn't need a token, so that a
+// client can be generated before anyone has one.
+const openAPIPath = "/openapi.json"
+
+// openAPIVersion is the version of the OpenAPI specification the document
+// follows. 3.0 rather than 3.1, because more code generators understand it.
+const openAPIVersion = "3.0.3"
+
+// WriteOpenAPI writes the mock API server's OpenAPI document to w. args are
+// the command line arguments following `openapi`.
+func WriteOpenAPI(w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	serverURL := flags.String("server-url", "http://127.0.0.1:8080", "The URL of the server, as the document should give it.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	s := newAPIServer(newBackend(""), apiServerOptions{})
+	out, err := json.MarshalIndent(openAPIDocument(s.routes, *serverURL), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// serveOpenAPI answers a request for the OpenAPI document. The server's URL
+// in the document is the one the request was made to.
+func (s *apiServer) serveOpenAPI(r *http.Request) (int, http.Header, []byte) {
+	if r.Method != http.MethodGet {
+		return s.errorResponse(&apiError{Code: apiErrorMethodNotAllowed, Message: fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path)}, nil)
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	body, err := json.MarshalIndent(openAPIDocument(s.routes, scheme+"://"+r.Host), "", "  ")
+	if err != nil {
+		return s.errorResponse(&apiError{Code: apiErrorInternal, Message: fmt.Sprintf("failed to encode OpenAPI document: %s", err)}, nil)
+	}
+	return http.StatusOK, http.Header{}, body
+}
+
+// openAPIDocument returns the OpenAPI document for routes.
+//
+// NOTE: The document is built out of maps rather than structs for the whole
+// of the OpenAPI specification, which would be a lot of types for the few
+// parts of it we need. encoding/json sorts map keys, so the output is still
+// the same every time.
+func openAPIDocument(routes []apiRoute, serverURL string) map[string]any {
+	g := &openAPISchemas{components: map[string]any{}}
+
+	paths := map[string]any{}
+	for i := range routes {
+		route := &routes[i]
+		item, ok := paths[route.path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[route.path] = item
+		}
+		item[strings.ToLower(route.method)] = g.operation(route)
+	}
+	paths[oauthTokenPath] = map[string]any{"post": g.tokenOperation()}
+
+	// Every error has the same body, and its code is one of a known set.
+	errorSchema := g.schemaFor(reflect.TypeOf(apiErrorResponse{}))
+	codes := make([]string, 0, len(apiErrorStatus))
+	for code := range apiErrorStatus {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	g.components[schemaName(reflect.TypeOf(apiError{}))].(map[string]any)["properties"].(map[string]any)["code"] = map[string]any{
+		"type": "string",
+		"enum": codes,
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "terraform-provider-mock API",
+			"version": "1",
+			"description": "The mock API server that terraform-provider-mock can be pointed at with its 'api_url' argument. " +
+				"A token is only needed when the server is started with -tokens-file.",
+		},
+		"servers": []any{map[string]any{"url": serverURL}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "An error, with a code saying what went wrong.",
+					"content":     jsonContent(errorSchema),
+				},
+			},
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A token from the server's tokens file.",
+				},
+				"oauth2": map[string]any{
+					"type":        "oauth2",
+					"description": "A short-lived token issued to one of the clients in the server's tokens file.",
+					"flows": map[string]any{
+						"clientCredentials": map[string]any{
+							"tokenUrl": serverURL + oauthTokenPath,
+							"scopes":   map[string]any{},
+						},
+					},
+				},
+				"clientBasic": map[string]any{
+					"type":        "http",
+					"scheme":      "basic",
+					"description": "A client's ID and secret, for the token endpoint.",
+				},
+			},
+		},
+		"security": []any{
+			map[string]any{"bearerAuth": []any{}},
+			map[string]any{"oauth2": []any{}},
+		},
+	}
+}
+
+// operation returns the OpenAPI operation for a route.
+func (g *openAPISchemas) operation(route *apiRoute) map[string]any {
+	doc := route.doc
+
+	// The tag groups the routes for the same kind of object, e.g. "counters".
+	tag := strings.SplitN(strings.TrimPrefix(route.path, "/v1/"), "/", 2)[0]
+
+	params := []any{}
+	for _, segment := range strings.Split(route.path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, map[string]any{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+	}
+	if route.method != http.MethodGet {
+		params = append(params, map[string]any{
+			"name":        idempotencyKeyHeader,
+			"in":          "header",
+			"description": "Identifies the request, so that retrying it doesn't make the change twice.",
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+
+	success := map[string]any{"description": http.StatusText(doc.status)}
+	if doc.response != nil {
+		success["content"] = jsonContent(g.schemaFor(reflect.TypeOf(doc.response)))
+	}
+
+	op := map[string]any{
+		"operationId": doc.id,
+		"summary":     doc.summary,
+		"tags":        []any{tag},
+		"responses": map[string]any{
+			fmt.Sprint(doc.status): success,
+			"default":              map[string]any{"$ref": "#/components/responses/Error"},
+		},
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if doc.request != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(g.schemaFor(reflect.TypeOf(doc.request))),
+		}
+	}
+	return op
+}
+
+// tokenOperation returns the OpenAPI operation for the OAuth2 token endpoint
+// (see issueToken), which isn't in the route table.
+func (g *openAPISchemas) tokenOperation() map[string]any {
+	oauthError := map[string]any{
+		"description": "An OAuth2 error (RFC 6749, section 5.2).",
+		"content":     jsonContent(g.schemaFor(reflect.TypeOf(oauthErrorResponse{}))),
+	}
+	return map[string]any{
+		"operationId": "issueToken",
+		"summary":     "Issues a short-lived access token to a client, with the client_credentials grant.",
+		"tags":        []any{"oauth"},
+		"security": []any{
+			map[string]any{},
+			map[string]any{"clientBasic": []any{}},
+		},
+		"requestBody": map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/x-www-form-urlencoded": map[string]any{
+					"schema": map[string]any{
+						"type":     "object",
+						"required": []any{"grant_type"},
+						"properties": map[string]any{
+							"grant_type":    map[string]any{"type": "string", "enum": []any{"client_credentials"}},
+							"client_id":     map[string]any{"type": "string"},
+							"client_secret": map[string]any{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "An access token (RFC 6749, section 5.1).",
+				"content":     jsonContent(g.schemaFor(reflect.TypeOf(oauthTokenResponse{}))),
+			},
+			"400":     oauthError,
+			"401":     oauthError,
+			"default": map[string]any{"$ref": "#/components/responses/Error"},
+		},
+	}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// openAPISchemas turns Go types into the JSON schemas OpenAPI uses. Every
+// struct becomes one of the document's components, which is referred to
+// wherever the struct is used.
+type openAPISchemas struct {
+	components map[string]any
+}
+
+// schemaFor returns the schema for the JSON encoding/json produces for t.
+func (g *openAPISchemas) schemaFor(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaFor(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := g.components[name]; !ok {
+			// The name is taken before the fields are looked at, in case
+			// the struct (indirectly) contains itself.
+			g.components[name] = nil
+			g.components[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		// Anything else (i.e. an interface) could be any JSON value.
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the schema of a struct's fields, as named by their
+// json tags.
+//
+// NOTE: A field without 'omitempty' is always sent, so it's required. And if
+// it's a pointer, slice or map it's sent as null when it's nil, so it's
+// nullable too. (OpenAPI 3.0 ignores 'nullable' next to a $ref, hence the
+// allOf.)
+func (g *openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := g.structSchema(f.Type)
+			for k, v := range embedded["properties"].(map[string]any) {
+				properties[k] = v
+			}
+			if r, ok := embedded["required"].([]any); ok {
+				required = append(required, r...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := g.schemaFor(f.Type)
+		omitEmpty := strings.Contains(","+opts+",", ",omitempty,")
+		if !omitEmpty {
+			required = append(required, name)
+			switch f.Type.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Map:
+				if _, ok := schema["$ref"]; ok {
+					schema = map[string]any{"allOf": []any{schema}}
+				}
+				schema["nullable"] = true
+			}
+		}
+		properties[name] = schema
+	}
+
+	s := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// schemaName is the name of a struct's schema, which is the name of the Go
+// type made to look like a type name in most other languages, e.g.
+// "CounterMember" for counterMember. The "api" some of the types are prefixed
+// with to tell them apart from the backend's types is dropped.
+func schemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "api")
+	if name == "" {
+		return "Object"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,504 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiRoute is one of the calls the mock API server understands.
+type apiRoute struct {
+	method string
+
+	// path may contain parameters, e.g. "/v1/examples/{id}", which are
+	// available to handle as apiRequest.params.
+	path string
+
+	// doc describes the route in the OpenAPI document (see api_openapi.go).
+	doc apiRouteDoc
+
+	// handle returns the HTTP status and the body to send back, or an error
+	// (see apiErrorFor).
+	handle func(r *apiRequest) (int, any, error)
+}
+
+// apiRouteDoc describes a route for the OpenAPI document.
+type apiRouteDoc struct {
+	// id is the route's operationId, which a client generated from the
+	// document names the method for the route after.
+	id      string
+	summary string
+
+	// request and response are zero values of the types of the request and
+	// response bodies, or nil when there isn't one. status is the status of a
+	// successful response.
+	request  any
+	status   int
+	response any
+}
+
+// apiRequest is what a route's handle function is given.
+type apiRequest struct {
+	ctx    context.Context
+	params map[string]string
+	body   []byte
+
+	// token is the bearer token the request was made with, if the server
+	// needs one.
+	token *apiToken
+}
+
+// decode decodes the JSON body of the request into v.
+func (r *apiRequest) decode(v any) error {
+	if err := json.Unmarshal(r.body, v); err != nil {
+		return &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    apiErrorBadRequest,
+			Message: fmt.Sprintf("invalid request body: %s", err),
+		}
+	}
+	return nil
+}
+
+// matchPath reports whether the (still escaped) path of a request matches a
+// route's path, returning the values of its parameters. The path is split on
+// "/" before it's unescaped, so a parameter value can contain "%2F".
+func matchPath(pattern, escapedPath string) (map[string]string, bool) {
+	want := strings.Split(pattern, "/")
+	got := strings.Split(escapedPath, "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i := range want {
+		if strings.HasPrefix(want[i], "{") && strings.HasSuffix(want[i], "}") {
+			v, err := url.PathUnescape(got[i])
+			if err != nil || v == "" {
+				return nil, false
+			}
+			params[strings.Trim(want[i], "{}")] = v
+			continue
+		}
+		if want[i] != got[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// apiRoutes returns every call the server understands. Objects are sent and
+// returned as the same JSON the backend persists them as.
+func (s *apiServer) apiRoutes() []apiRoute {
+	b := s.backend
+
+	return []apiRoute{
+		// Counters are identified by their name.
+		{http.MethodPost, "/v1/counters", apiRouteDoc{
+			id:       "createCounter",
+			summary:  "Creates a counter.",
+			request:  counter{},
+			status:   http.StatusCreated,
+			response: counter{},
+		}, func(r *apiRequest) (int, any, error) {
+			var c counter
+			if err := r.decode(&c); err != nil {
+				return 0, nil, err
+			}
+			if err := b.createCounter(r.ctx, c); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusCreated, c, nil
+		}},
+		{http.MethodGet, "/v1/counters/{name}", apiRouteDoc{
+			id:       "getCounter",
+			summary:  "Returns a counter.",
+			status:   http.StatusOK,
+			response: counter{},
+		}, func(r *apiRequest) (int, any, error) {
+			c, err := b.getCounter(r.ctx, r.params["name"])
+			return http.StatusOK, c, err
+		}},
+		{http.MethodPut, "/v1/counters/{name}/value", apiRouteDoc{
+			id:      "setCounterValue",
+			summary: "Sets the value of a counter.",
+			request: counterValueRequest{},
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			var req counterValueRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusNoContent, nil, b.setCounterValue(r.ctx, r.params["name"], req.Value)
+		}},
+		{http.MethodDelete, "/v1/counters/{name}", apiRouteDoc{
+			id:      "deleteCounter",
+			summary: "Deletes a counter, which mustn't have any members.",
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			return http.StatusNoContent, nil, b.deleteCounter(r.ctx, r.params["name"])
+		}},
+
+		{http.MethodPost, "/v1/counter_members", apiRouteDoc{
+			id:       "createCounterMember",
+			summary:  "Creates a counter member.",
+			request:  counterMember{},
+			status:   http.StatusCreated,
+			response: counterMember{},
+		}, func(r *apiRequest) (int, any, error) {
+			var m counterMember
+			if err := r.decode(&m); err != nil {
+				return 0, nil, err
+			}
+			if err := b.createCounterMember(r.ctx, m); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusCreated, m, nil
+		}},
+		{http.MethodGet, "/v1/counter_members/{id}", apiRouteDoc{
+			id:       "getCounterMember",
+			summary:  "Returns a counter member.",
+			status:   http.StatusOK,
+			response: counterMember{},
+		}, func(r *apiRequest) (int, any, error) {
+			m, err := b.getCounterMember(r.ctx, r.params["id"])
+			return http.StatusOK, m, err
+		}},
+		{http.MethodDelete, "/v1/counter_members/{id}", apiRouteDoc{
+			id:      "deleteCounterMember",
+			summary: "Deletes a counter member.",
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			return http.StatusNoContent, nil, b.deleteCounterMember(r.ctx, r.params["id"])
+		}},
+
+		// The value of a secret is accepted but never returned, only its
+		// hash (see backend_secret.go).
+		{http.MethodPost, "/v1/secrets", apiRouteDoc{
+			id:       "createSecret",
+			summary:  "Creates a secret, generating its API key.",
+			request:  secret{},
+			status:   http.StatusCreated,
+			response: secret{},
+		}, func(r *apiRequest) (int, any, error) {
+			var sec secret
+			if err := r.decode(&sec); err != nil {
+				return 0, nil, err
+			}
+			created, err := b.createSecret(r.ctx, sec)
+			return http.StatusCreated, created, err
+		}},
+		{http.MethodGet, "/v1/secrets/{id}", apiRouteDoc{
+			id:       "getSecret",
+			summary:  "Returns a secret, with the hash of its value rather than the value.",
+			status:   http.StatusOK,
+			response: secret{},
+		}, func(r *apiRequest) (int, any, error) {
+			sec, err := b.getSecret(r.ctx, r.params["id"])
+			return http.StatusOK, sec, err
+		}},
+		{http.MethodPut, "/v1/secrets/{id}/value", apiRouteDoc{
+			id:      "setSecretValue",
+			summary: "Sets the value of a secret.",
+			request: secretValueRequest{},
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			var req secretValueRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusNoContent, nil, b.setSecretValue(r.ctx, r.params["id"], req.Value)
+		}},
+		{http.MethodPost, "/v1/secrets/{id}/rotate_api_key", apiRouteDoc{
+			id:       "rotateSecretAPIKey",
+			summary:  "Replaces the API key of a secret.",
+			status:   http.StatusOK,
+			response: secret{},
+		}, func(r *apiRequest) (int, any, error) {
+			sec, err := b.rotateSecretAPIKey(r.ctx, r.params["id"])
+			return http.StatusOK, sec, err
+		}},
+		{http.MethodDelete, "/v1/secrets/{id}", apiRouteDoc{
+			id:      "deleteSecret",
+			summary: "Deletes a secret.",
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			return http.StatusNoContent, nil, b.deleteSecret(r.ctx, r.params["id"])
+		}},
+
+		{http.MethodPost, "/v1/parents", apiRouteDoc{
+			id:       "createParent",
+			summary:  "Creates a parent.",
+			request:  parent{},
+			status:   http.StatusCreated,
+			response: parent{},
+		}, func(r *apiRequest) (int, any, error) {
+			var p parent
+			if err := r.decode(&p); err != nil {
+				return 0, nil, err
+			}
+			if err := b.createParent(r.ctx, p); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusCreated, p, nil
+		}},
+		{http.MethodGet, "/v1/parents/{id}", apiRouteDoc{
+			id:       "getParent",
+			summary:  "Returns a parent.",
+			status:   http.StatusOK,
+			response: parent{},
+		}, func(r *apiRequest) (int, any, error) {
+			p, err := b.getParent(r.ctx, r.params["id"])
+			return http.StatusOK, p, err
+		}},
+		{http.MethodPut, "/v1/parents/{id}", apiRouteDoc{
+			id:      "updateParent",
+			summary: "Updates a parent.",
+			request: parent{},
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			var p parent
+			if err := r.decode(&p); err != nil {
+				return 0, nil, err
+			}
+			p.ID = r.params["id"]
+			return http.StatusNoContent, nil, b.updateParent(r.ctx, p)
+		}},
+		{http.MethodDelete, "/v1/parents/{id}", apiRouteDoc{
+			id:      "deleteParent",
+			summary: "Deletes a parent, which mustn't have any children.",
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			return http.StatusNoContent, nil, b.deleteParent(r.ctx, r.params["id"])
+		}},
+
+		{http.MethodPost, "/v1/children", apiRouteDoc{
+			id:       "createChild",
+			summary:  "Creates a child of a parent.",
+			request:  child{},
+			status:   http.StatusCreated,
+			response: child{},
+		}, func(r *apiRequest) (int, any, error) {
+			var c child
+			if err := r.decode(&c); err != nil {
+				return 0, nil, err
+			}
+			if err := b.createChild(r.ctx, c); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusCreated, c, nil
+		}},
+		{http.MethodGet, "/v1/children/{id}", apiRouteDoc{
+			id:       "getChild",
+			summary:  "Returns a child.",
+			status:   http.StatusOK,
+			response: child{},
+		}, func(r *apiRequest) (int, any, error) {
+			c, err := b.getChild(r.ctx, r.params["id"])
+			return http.StatusOK, c, err
+		}},
+		{http.MethodPut, "/v1/children/{id}", apiRouteDoc{
+			id:      "updateChild",
+			summary: "Updates a child.",
+			request: child{},
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			var c child
+			if err := r.decode(&c); err != nil {
+				return 0, nil, err
+			}
+			c.ID = r.params["id"]
+			return http.StatusNoContent, nil, b.updateChild(r.ctx, c)
+		}},
+		{http.MethodDelete, "/v1/children/{id}", apiRouteDoc{
+			id:      "deleteChild",
+			summary: "Deletes a child.",
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			return http.StatusNoContent, nil, b.deleteChild(r.ctx, r.params["id"])
+		}},
+
+		{http.MethodPost, "/v1/all_types", apiRouteDoc{
+			id:       "createAllTypes",
+			summary:  "Creates an all_types object.",
+			request:  allTypes{},
+			status:   http.StatusCreated,
+			response: allTypes{},
+		}, func(r *apiRequest) (int, any, error) {
+			var a allTypes
+			if err := r.decode(&a); err != nil {
+				return 0, nil, err
+			}
+			if err := b.createAllTypes(r.ctx, a); err != nil {
+				return 0, nil, err
+			}
+			return http.StatusCreated, a, nil
+		}},
+		{http.MethodGet, "/v1/all_types/{id}", apiRouteDoc{
+			id:       "getAllTypes",
+			summary:  "Returns an all_types object.",
+			status:   http.StatusOK,
+			response: allTypes{},
+		}, func(r *apiRequest) (int, any, error) {
+			a, err := b.getAllTypes(r.ctx, r.params["id"])
+			return http.StatusOK, a, err
+		}},
+		{http.MethodPut, "/v1/all_types/{id}", apiRouteDoc{
+			id:      "updateAllTypes",
+			summary: "Updates an all_types object.",
+			request: allTypes{},
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			var a allTypes
+			if err := r.decode(&a); err != nil {
+				return 0, nil, err
+			}
+			a.ID = r.params["id"]
+			return http.StatusNoContent, nil, b.updateAllTypes(r.ctx, a)
+		}},
+		{http.MethodDelete, "/v1/all_types/{id}", apiRouteDoc{
+			id:      "deleteAllTypes",
+			summary: "Deletes an all_types object.",
+			status:  http.StatusNoContent,
+		}, func(r *apiRequest) (int, any, error) {
+			return http.StatusNoContent, nil, b.deleteAllTypes(r.ctx, r.params["id"])
+		}},
+
+		// Every change to an example returns an operation (see
+		// backend_operation.go), which is polled at /v1/operations/{id}.
+		{http.MethodPost, "/v1/examples", apiRouteDoc{
+			id:       "createExample",
+			summary:  "Queues the creation of an example.",
+			request:  example{},
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			var e example
+			if err := r.decode(&e); err != nil {
+				return 0, nil, err
+			}
+			op, err := b.createExample(r.ctx, e)
+			return http.StatusAccepted, op, err
+		}},
+		{http.MethodGet, "/v1/examples/{id}", apiRouteDoc{
+			id:       "getExample",
+			summary:  "Returns an example.",
+			status:   http.StatusOK,
+			response: example{},
+		}, func(r *apiRequest) (int, any, error) {
+			e, err := b.getExample(r.ctx, r.params["id"])
+			return http.StatusOK, e, err
+		}},
+		{http.MethodPut, "/v1/examples/{id}/fields", apiRouteDoc{
+			id:       "setExampleFields",
+			summary:  "Queues a change to the top-level fields of an example.",
+			request:  exampleFieldsRequest{},
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			var req exampleFieldsRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			op, err := b.setExampleFields(r.ctx, r.params["id"], req.Revision, example{
+				NotComputedOptional: req.NotComputedOptional,
+				NotComputedRequired: req.NotComputedRequired,
+				Tags:                req.Tags,
+			})
+			return http.StatusAccepted, op, err
+		}},
+		{http.MethodPut, "/v1/examples/{id}/foo", apiRouteDoc{
+			id:       "setExampleFoo",
+			summary:  "Queues the replacement of the foo blocks of an example.",
+			request:  exampleFooRequest{},
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			var req exampleFooRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			op, err := b.setExampleFoo(r.ctx, r.params["id"], req.Revision, req.Foo)
+			return http.StatusAccepted, op, err
+		}},
+		{http.MethodPatch, "/v1/examples/{id}/foo", apiRouteDoc{
+			id:       "patchExampleFoo",
+			summary:  "Queues a change to some of the foo blocks of an example.",
+			request:  exampleFooPatchRequest{},
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			var req exampleFooPatchRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			op, err := b.patchExampleFoo(r.ctx, r.params["id"], req.Revision, req.Ops)
+			return http.StatusAccepted, op, err
+		}},
+		{http.MethodPut, "/v1/examples/{id}/baz", apiRouteDoc{
+			id:       "setExampleBaz",
+			summary:  "Queues the replacement of the baz blocks of an example.",
+			request:  exampleBazRequest{},
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			var req exampleBazRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			op, err := b.setExampleBaz(r.ctx, r.params["id"], req.Revision, req.Baz)
+			return http.StatusAccepted, op, err
+		}},
+		{http.MethodPut, "/v1/examples/{id}/some_list", apiRouteDoc{
+			id:       "setExampleSomeList",
+			summary:  "Queues the replacement of the some_list of an example.",
+			request:  exampleSomeListRequest{},
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			var req exampleSomeListRequest
+			if err := r.decode(&req); err != nil {
+				return 0, nil, err
+			}
+			op, err := b.setExampleSomeList(r.ctx, r.params["id"], req.Revision, req.SomeList)
+			return http.StatusAccepted, op, err
+		}},
+		{http.MethodDelete, "/v1/examples/{id}", apiRouteDoc{
+			id:       "deleteExample",
+			summary:  "Queues the deletion of an example.",
+			status:   http.StatusAccepted,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			op, err := b.deleteExample(r.ctx, r.params["id"])
+			return http.StatusAccepted, op, err
+		}},
+
+		{http.MethodGet, "/v1/operations/{id}", apiRouteDoc{
+			id:       "getOperation",
+			summary:  "Returns an operation, to see whether it has finished.",
+			status:   http.StatusOK,
+			response: operation{},
+		}, func(r *apiRequest) (int, any, error) {
+			op, err := b.getOperation(r.ctx, r.params["id"])
+			return http.StatusOK, op, err
+		}},
+
+		// whoami describes the token the request was made with, so that a
+		// client can check its token before it needs it. Without a tokens
+		// file anyone can do anything.
+		{http.MethodGet, "/v1/whoami", apiRouteDoc{
+			id:       "whoami",
+			summary:  "Describes the token the request was made with.",
+			status:   http.StatusOK,
+			response: apiToken{},
+		}, func(r *apiRequest) (int, any, error) {
+			if r.token == nil {
+				return http.StatusOK, apiToken{Name: "anonymous", Scope: tokenScopeReadWrite}, nil
+			}
+			return http.StatusOK, apiToken{Name: r.token.Name, Scope: r.token.Scope}, nil
+		}},
+	}
+}
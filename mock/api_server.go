@@ -0,0 +1,411 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The mock API server puts the backend behind an HTTP API, so that the
+// provider can be run against something that behaves like a real remote API,
+// i.e. something that is rate limited, that fails now and then, and that
+// sometimes does what it was asked without the client ever hearing back.
+//
+// It's started with `terraform-provider-mock serve-api` (see ServeAPI), and
+// the provider is pointed at it with its 'api_url' argument.
+
+// apiServerOptions are the knobs for making the server misbehave.
+type apiServerOptions struct {
+	// RateLimit is how many requests per second are allowed (0 means there's
+	// no limit), in bursts of up to RateBurst. Any more are rejected with a
+	// 429 and a Retry-After header.
+	RateLimit float64
+	RateBurst int
+
+	// ErrorRate is the probability (0 to 1) of a request failing with a 503
+	// before it's processed.
+	ErrorRate float64
+
+	// LostResponseRate is the probability (0 to 1) of a change being made but
+	// answered with a 504, as though a load balancer gave up waiting for it.
+	// Retrying it is only safe because of idempotency keys.
+	LostResponseRate float64
+}
+
+// apiServer is the http.Handler serving the mock API.
+type apiServer struct {
+	backend     *backend
+	opts        apiServerOptions
+	routes      []apiRoute
+	limiter     *rateLimiter
+	idempotency *idempotencyStore
+
+	// auth decides which requests are allowed (see api_auth.go). When it's
+	// nil no token is needed.
+	auth *apiAuth
+}
+
+func newAPIServer(b *backend, opts apiServerOptions) *apiServer {
+	s := &apiServer{
+		backend:     b,
+		opts:        opts,
+		idempotency: newIdempotencyStore(),
+	}
+	s.routes = s.apiRoutes()
+	if opts.RateLimit > 0 {
+		s.limiter = newRateLimiter(opts.RateLimit, opts.RateBurst)
+	}
+	return s
+}
+
+// ServeAPI runs the mock API server until it fails. args are the command line
+// arguments following `serve-api`.
+func ServeAPI(args []string) error {
+	flags := flag.NewFlagSet("serve-api", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "The address to listen on.")
+	// The provider's default, so that terraform run in the same directory
+	// finds the same objects with or without the server.
+	defaultBackend, err := defaultBackendPath()
+	if err != nil {
+		log.Printf(">>> keeping the backend in memory by default, as the default -backend-file can't be used: %s", err)
+	}
+	backendFile := flags.String("backend-file", defaultBackend, "Path to the JSON file the backend persists its objects to. An empty string keeps everything in memory. Defaults to the provider's default backend_file for the current directory.")
+	tokensFile := flags.String("tokens-file", "", "Path to a JSON file defining the bearer tokens and OAuth2 clients the server accepts. Without one, no token is needed.")
+	tokenLifetime := flags.Duration("token-lifetime", defaultTokenLifetime, "How long an access token issued to an OAuth2 client lasts.")
+	useTLS := flags.Bool("tls", false, "Serve HTTPS, with certificates signed by a CA the server generates (see -tls-dir).")
+	tlsDir := flags.String("tls-dir", "mock-tls", "The directory the CA, server and client certificates are kept in.")
+	requireClientCert := flags.Bool("require-client-cert", false, "Require clients to present a certificate signed by the server's CA (mutual TLS). Implies -tls.")
+
+	var opts apiServerOptions
+	flags.Float64Var(&opts.RateLimit, "rate-limit", 0, "How many requests per second are allowed (0 means there's no limit).")
+	flags.IntVar(&opts.RateBurst, "rate-burst", 10, "How many requests can be made at once before the rate limit kicks in.")
+	flags.Float64Var(&opts.ErrorRate, "error-rate", 0, "The probability (between 0 and 1) of a request failing with a 503.")
+	flags.Float64Var(&opts.LostResponseRate, "lost-response-rate", 0, "The probability (between 0 and 1) of a change being made but answered with a 504.")
+
+	// The same options as the provider's async_operations block.
+	b := newBackend("")
+	flags.BoolVar(&b.async.Enabled, "async", false, "Return long-running operations from changes to examples, which the client then has to poll.")
+	flags.DurationVar(&b.async.PendingDuration, "async-pending-duration", 2*time.Second, "How long an operation stays PENDING.")
+	flags.DurationVar(&b.async.RunningDuration, "async-running-duration", 5*time.Second, "How long an operation stays RUNNING before it's DONE (or FAILED).")
+	flags.Float64Var(&b.async.FailureRate, "async-failure-rate", 0, "The probability (between 0 and 1) of an operation ending up FAILED.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	b.path = *backendFile
+	if b.path != "" {
+		log.Printf(">>> mock backend persisted to %s", b.path)
+	}
+	if *tokenLifetime < time.Second {
+		return fmt.Errorf("-token-lifetime must be at least 1s, as tokens expire after a whole number of seconds")
+	}
+
+	s := newAPIServer(b, opts)
+	if *tokensFile != "" {
+		auth, err := loadAPIAuth(*tokensFile, *tokenLifetime)
+		if err != nil {
+			return err
+		}
+		s.auth = auth
+		log.Printf(">>> %d bearer tokens and %d OAuth2 clients loaded from %s", len(auth.tokens), len(auth.clients), *tokensFile)
+	}
+
+	if !*useTLS && !*requireClientCert {
+		log.Printf(">>> mock API server listening on http://%s", *addr)
+		return http.ListenAndServe(*addr, s)
+	}
+
+	tlsConfig, err := serverTLSConfig(*tlsDir, *addr, *requireClientCert)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: *addr, Handler: s, TLSConfig: tlsConfig}
+	log.Printf(">>> mock API server listening on https://%s (CA certificate: %s)", *addr, filepath.Join(*tlsDir, tlsCAFile))
+	if *requireClientCert {
+		log.Printf(">>> client certificates are required (e.g. %s)", filepath.Join(*tlsDir, tlsClientFile))
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status, header, body := s.serve(r)
+
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	if body != nil {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+
+	log.Printf(">>> %s %s: %d (%s)", r.Method, r.URL.RequestURI(), status, time.Since(start).Round(time.Millisecond))
+}
+
+// serve handles a request, returning the response to send.
+func (s *apiServer) serve(r *http.Request) (int, http.Header, []byte) {
+	if s.limiter != nil {
+		if wait, ok := s.limiter.take(time.Now()); !ok {
+			return s.errorResponse(&apiError{
+				Code:    apiErrorRateLimited,
+				Message: fmt.Sprintf("rate limit of %g requests per second exceeded", s.opts.RateLimit),
+			}, retryAfter(wait))
+		}
+	}
+	if rand.Float64() < s.opts.ErrorRate {
+		return s.errorResponse(&apiError{
+			Code:    apiErrorUnavailable,
+			Message: "the service is temporarily unavailable (simulated failure)",
+		}, retryAfter(time.Second))
+	}
+
+	if r.URL.Path == oauthTokenPath {
+		return s.issueToken(r)
+	}
+	if r.URL.Path == openAPIPath {
+		return s.serveOpenAPI(r)
+	}
+
+	token, authErr := s.authenticate(r)
+	if authErr != nil {
+		return s.errorResponse(authErr, unauthorizedHeader())
+	}
+
+	var route *apiRoute
+	var params map[string]string
+	methodMismatch := false
+	for i := range s.routes {
+		p, ok := matchPath(s.routes[i].path, r.URL.EscapedPath())
+		if !ok {
+			continue
+		}
+		if s.routes[i].method != r.Method {
+			methodMismatch = true
+			continue
+		}
+		route, params = &s.routes[i], p
+		break
+	}
+	if route == nil {
+		if methodMismatch {
+			return s.errorResponse(&apiError{Code: apiErrorMethodNotAllowed, Message: fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path)}, nil)
+		}
+		return s.errorResponse(&apiError{Code: apiErrorNoSuchPath, Message: fmt.Sprintf("no such path %s", r.URL.Path)}, nil)
+	}
+	if authErr := authorize(token, r); authErr != nil {
+		return s.errorResponse(authErr, nil)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return s.errorResponse(&apiError{Code: apiErrorBadRequest, Message: fmt.Sprintf("failed to read request body: %s", err)}, nil)
+	}
+	req := &apiRequest{ctx: r.Context(), params: params, body: body, token: token}
+
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" || r.Method == http.MethodGet {
+		status, header, respBody := s.handle(route, req)
+		return s.maybeLoseResponse(r, status, header, respBody)
+	}
+
+	// NOTE: Requests with an idempotency key are handled one at a time, so
+	// that a retry can't overtake the request it's retrying. That costs
+	// nothing here, because the backend only makes one change at a time
+	// anyway, but a real API would lock each key separately.
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+
+	if recorded, ok := s.idempotency.lookup(key, time.Now()); ok {
+		if recorded.method != r.Method || recorded.path != r.URL.Path {
+			return s.errorResponse(&apiError{
+				Code:    apiErrorIdempotencyKey,
+				Message: fmt.Sprintf("idempotency key %q was already used for %s %s", key, recorded.method, recorded.path),
+			}, nil)
+		}
+		log.Printf(">>> replaying the response to idempotency key %q", key)
+		header := recorded.header.Clone()
+		header.Set(idempotentReplayedHeader, "true")
+		return recorded.status, header, recorded.body
+	}
+
+	status, header, respBody := s.handle(route, req)
+
+	// Only the outcome of actually processing the request is worth
+	// remembering. A request that failed for a reason that has nothing to
+	// do with its content (e.g. the backend file couldn't be read) should
+	// be processed again when it's retried.
+	if status < http.StatusInternalServerError {
+		s.idempotency.record(key, &recordedResponse{
+			method:  r.Method,
+			path:    r.URL.Path,
+			status:  status,
+			header:  header,
+			body:    respBody,
+			created: time.Now(),
+		})
+	}
+	return s.maybeLoseResponse(r, status, header, respBody)
+}
+
+// handle calls the route's handle function and encodes what it returns.
+func (s *apiServer) handle(route *apiRoute, req *apiRequest) (int, http.Header, []byte) {
+	status, resp, err := route.handle(req)
+	if err != nil {
+		return s.errorResponse(apiErrorFor(err), nil)
+	}
+	if resp == nil {
+		return status, http.Header{}, nil
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return s.errorResponse(&apiError{Code: apiErrorInternal, Message: fmt.Sprintf("failed to encode response: %s", err)}, nil)
+	}
+	return status, http.Header{}, body
+}
+
+// maybeLoseResponse replaces the response to a change with a 504 when the
+// LostResponseRate says so. The change has still been made.
+func (s *apiServer) maybeLoseResponse(r *http.Request, status int, header http.Header, body []byte) (int, http.Header, []byte) {
+	if r.Method == http.MethodGet || rand.Float64() >= s.opts.LostResponseRate {
+		return status, header, body
+	}
+	log.Printf(">>> losing the response to %s %s (it was %d)", r.Method, r.URL.Path, status)
+	return s.errorResponse(&apiError{
+		Code:    apiErrorGatewayTimeout,
+		Message: "timed out waiting for the backend (simulated failure, the request may still have been processed)",
+	}, nil)
+}
+
+// errorResponse encodes an error response.
+func (s *apiServer) errorResponse(e *apiError, header http.Header) (int, http.Header, []byte) {
+	if e.Status == 0 {
+		e.Status = apiErrorStatus[e.Code]
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	body, _ := json.Marshal(apiErrorResponse{Error: e})
+	return e.Status, header, body
+}
+
+// apiErrorFor turns an error returned by the backend into an apiError.
+func apiErrorFor(err error) *apiError {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e
+	}
+
+	e = &apiError{Code: errorCode(err), Message: err.Error()}
+	if e.Code == "" {
+		e.Code = apiErrorInternal
+	}
+
+	var exists *alreadyExistsError
+	if errors.As(err, &exists) {
+		e.Kind, e.Name, e.ID = exists.kind, exists.name, exists.id
+	}
+
+	e.Status = apiErrorStatus[e.Code]
+	return e
+}
+
+// retryAfter returns a header telling the client to wait for d before trying
+// again. Retry-After is in whole seconds, so d is rounded up.
+func retryAfter(d time.Duration) http.Header {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return http.Header{"Retry-After": []string{strconv.Itoa(seconds)}}
+}
+
+// rateLimiter is a token bucket: it holds up to burst tokens, is topped up at
+// rate tokens per second, and each request takes one.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take takes a token if there's one, and otherwise returns how long it will
+// be until there is.
+func (l *rateLimiter) take(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second)), false
+}
+
+// idempotencyRetention is how long the response to a request with an
+// idempotency key is kept for.
+const idempotencyRetention = 24 * time.Hour
+
+// idempotencyStore remembers the responses to requests that had an
+// idempotency key, so that a retry gets the same response rather than making
+// the change again. It only lives in memory, so restarting the server forgets
+// every key.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*recordedResponse
+}
+
+type recordedResponse struct {
+	method  string
+	path    string
+	status  int
+	header  http.Header
+	body    []byte
+	created time.Time
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{responses: make(map[string]*recordedResponse)}
+}
+
+// lookup returns the response recorded for key. The caller must hold mu.
+func (s *idempotencyStore) lookup(key string, now time.Time) (*recordedResponse, bool) {
+	for k, resp := range s.responses {
+		if now.Sub(resp.created) > idempotencyRetention {
+			delete(s.responses, k)
+		}
+	}
+	resp, ok := s.responses[key]
+	return resp, ok
+}
+
+// record records the response for key. The caller must hold mu.
+func (s *idempotencyStore) record(key string, resp *recordedResponse) {
+	s.responses[key] = resp
+}
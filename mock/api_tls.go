@@ -0,0 +1,297 @@
+package mock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// The mock API server can serve HTTPS (see the -tls flag of ServeAPI), and
+// can require clients to present a certificate too ('mutual TLS', see
+// -require-client-cert), so that the provider's TLS settings can be tried out
+// without a real certificate authority.
+//
+// The server makes its own CA, and uses it to sign a certificate for itself
+// and one for a client. They're kept in the -tls-dir directory:
+//
+//	ca.pem, ca-key.pem          The CA, for the provider's 'ca_cert_file'.
+//	server.pem, server-key.pem  The server's certificate.
+//	client.pem, client-key.pem  A client certificate, for the provider's
+//	                            'client_cert_file' and 'client_key_file'.
+//
+// The CA and the client certificate are only generated once, so that the
+// provider's configuration keeps working when the server is restarted. The
+// server's certificate is generated every time the server starts, as it has
+// to be valid for whatever address it's listening on.
+
+// The files in the -tls-dir directory.
+const (
+	tlsCAFile         = "ca.pem"
+	tlsCAKeyFile      = "ca-key.pem"
+	tlsServerFile     = "server.pem"
+	tlsServerKeyFile  = "server-key.pem"
+	tlsClientFile     = "client.pem"
+	tlsClientKeyFile  = "client-key.pem"
+	tlsCertificateTTL = 365 * 24 * time.Hour
+)
+
+// serverTLSConfig returns the TLS configuration for a server listening on
+// addr, generating whatever certificates are missing from dir.
+func serverTLSConfig(dir, addr string, requireClientCert bool) (*tls.Config, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create TLS directory: %w", err)
+	}
+
+	ca, caKey, created, err := loadOrCreateCA(dir)
+	if err != nil {
+		return nil, err
+	}
+	// A new CA needs a new client certificate, as the old one (if any) was
+	// signed by the old CA.
+	if _, err := os.Stat(filepath.Join(dir, tlsClientFile)); created || errors.Is(err, os.ErrNotExist) {
+		template := &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "terraform-provider-mock client"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		if err := createCertificate(dir, tlsClientFile, tlsClientKeyFile, template, ca, caKey); err != nil {
+			return nil, err
+		}
+		log.Printf(">>> generated a client certificate: %s", filepath.Join(dir, tlsClientFile))
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "terraform-provider-mock server"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if host != "" && ip == nil {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+	if err := createCertificate(dir, tlsServerFile, tlsServerKeyFile, template, ca, caKey); err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, tlsServerFile), filepath.Join(dir, tlsServerKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if requireClientCert {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// loadOrCreateCA returns the CA in dir, creating it if there isn't one (in
+// which case created is true).
+func loadOrCreateCA(dir string) (ca *x509.Certificate, key *ecdsa.PrivateKey, created bool, err error) {
+	certPath, keyPath := filepath.Join(dir, tlsCAFile), filepath.Join(dir, tlsCAKeyFile)
+
+	if _, err := os.Stat(certPath); errors.Is(err, os.ErrNotExist) {
+		template := &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "terraform-provider-mock CA"},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}
+		if err := createCertificate(dir, tlsCAFile, tlsCAKeyFile, template, nil, nil); err != nil {
+			return nil, nil, false, err
+		}
+		log.Printf(">>> generated a CA certificate: %s", certPath)
+		created = true
+	}
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to load CA certificate: %w", err)
+	}
+	if ca, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		return nil, nil, false, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, false, fmt.Errorf("%s isn't an ECDSA key", keyPath)
+	}
+	return ca, key, created, nil
+}
+
+// createCertificate generates a key and a certificate from template, signed
+// by the CA (or by itself when ca is nil), and writes them to dir.
+func createCertificate(dir, certFile, keyFile string, template, ca *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(tlsCertificateTTL)
+	if template.KeyUsage == 0 {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+	}
+
+	parent, parentKey := ca, caKey
+	if ca == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate %s: %w", certFile, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key %s: %w", keyFile, err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, certFile), certPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, keyFile), keyPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
+
+// apiTLSOptions are the provider arguments that configure how the client
+// talks to a server over HTTPS.
+type apiTLSOptions struct {
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool
+}
+
+// clientTLSConfig returns the TLS configuration for the apiClient, or nil if
+// the defaults will do. Anything wrong with the files is reported against
+// the argument that named them.
+func clientTLSConfig(opts apiTLSOptions) (*tls.Config, diag.Diagnostics) {
+	if opts == (apiTLSOptions{}) {
+		return nil, nil
+	}
+
+	var diags diag.Diagnostics
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CACertFile != "" {
+		caPEM, err := os.ReadFile(opts.CACertFile)
+		if err == nil {
+			// The CA is trusted as well as the system's CAs, not instead of
+			// them, as a proxy in between might have a real certificate.
+			pool, poolErr := x509.SystemCertPool()
+			if poolErr != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(caPEM) {
+				err = errors.New("it doesn't contain any PEM encoded certificates")
+			}
+			config.RootCAs = pool
+		}
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Invalid CA certificate",
+				Detail:        fmt.Sprintf("Unable to use %s as a CA certificate: %s", opts.CACertFile, err),
+				AttributePath: cty.GetAttrPath("ca_cert_file"),
+			})
+		}
+	}
+
+	switch {
+	case opts.ClientCertFile != "" && opts.ClientKeyFile != "":
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Invalid client certificate",
+				Detail:        fmt.Sprintf("Unable to use %s and %s as a client certificate and key: %s", opts.ClientCertFile, opts.ClientKeyFile, err),
+				AttributePath: cty.GetAttrPath("client_cert_file"),
+			})
+			break
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case opts.ClientCertFile != "" || opts.ClientKeyFile != "":
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "Incomplete client certificate",
+			Detail:        "`client_cert_file` (MOCK_CLIENT_CERT_FILE) and `client_key_file` (MOCK_CLIENT_KEY_FILE) have to be set together.",
+			AttributePath: cty.GetAttrPath("client_cert_file"),
+		})
+	}
+
+	if opts.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Warning,
+			Summary:       "The mock API server's certificate isn't being verified",
+			Detail:        "With `insecure_skip_verify` set, anyone in between the provider and the server could read and change what they send each other, tokens included. Set `ca_cert_file` instead.",
+			AttributePath: cty.GetAttrPath("insecure_skip_verify"),
+		})
+	}
+
+	return config, diags
+}
+
+// tlsErrorHint explains the usual ways of getting TLS wrong, given the error
+// a request failed with. It returns an empty string for any other error.
+//
+// NOTE: None of these are worth retrying, as they'll fail the same way every
+// time, so the apiClient gives up on them straight away.
+func tlsErrorHint(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "The server's certificate isn't signed by a CA the provider trusts. Set `ca_cert_file` to the CA certificate the server was started with."
+	case errors.As(err, &hostname):
+		return fmt.Sprintf("The server's certificate isn't valid for %q. Check the host in `api_url` is one the certificate was made for.", hostname.Host)
+	case errors.As(err, &invalid):
+		return "The server's certificate isn't valid (e.g. it has expired). Restarting the server gives it a new one."
+	case strings.Contains(err.Error(), "server gave HTTP response to HTTPS client"):
+		return "`api_url` starts with https://, but the server isn't serving HTTPS. Start it with -tls, or use http://."
+	case strings.Contains(err.Error(), "Client sent an HTTP request to an HTTPS server"):
+		return "The server is serving HTTPS, but `api_url` starts with http://. Use https://, and set `ca_cert_file` to the CA certificate the server was started with."
+	case strings.Contains(err.Error(), "remote error: tls:"):
+		// The server ended the handshake, which (for the mock API server at
+		// least) means it didn't accept the client's certificate, or that
+		// there wasn't one.
+		return "The server rejected the connection, which usually means it requires a client certificate and didn't accept the provider's. Set `client_cert_file` and `client_key_file` to a certificate signed by the server's CA."
+	}
+	return ""
+}
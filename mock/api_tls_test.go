@@ -0,0 +1,115 @@
+package mock
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+)
+
+// testTLSAPIServer starts the mock API server over HTTPS, with the
+// certificates it generates in dir, like `serve-api -tls` does.
+func testTLSAPIServer(t *testing.T, dir string, requireClientCert bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(newAPIServer(newBackend(""), apiServerOptions{}))
+	config, err := serverTLSConfig(dir, server.Listener.Addr().String(), requireClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientTLSConfigDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	server := testTLSAPIServer(t, dir, false)
+	mtls := testTLSAPIServer(t, dir, true)
+	ca := filepath.Join(dir, tlsCAFile)
+
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	const secure = "^Unable to connect to the mock API server securely$"
+	for _, tc := range []struct {
+		name            string
+		config          map[string]any
+		summary, detail string
+	}{
+		{
+			name:    "untrusted CA",
+			config:  map[string]any{"api_url": server.URL},
+			summary: secure,
+			detail:  "certificate signed by unknown authority(?s).*The server's certificate isn't signed by a CA the provider trusts\\. Set `ca_cert_file`",
+		},
+		{
+			name:    "http to an https server",
+			config:  map[string]any{"api_url": strings.Replace(server.URL, "https://", "http://", 1)},
+			summary: secure,
+			detail:  "Client sent an HTTP request to an HTTPS server(?s).*The server is serving HTTPS, but `api_url` starts with http://\\. Use https://",
+		},
+		{
+			name:    "missing client certificate",
+			config:  map[string]any{"api_url": mtls.URL, "ca_cert_file": ca},
+			summary: secure,
+			detail:  "remote error: tls: (?s).*requires a client certificate and didn't accept the provider's\\. Set `client_cert_file` and `client_key_file`",
+		},
+		{
+			name: "certificate and key mismatch",
+			config: map[string]any{
+				"api_url":          mtls.URL,
+				"ca_cert_file":     ca,
+				"client_cert_file": filepath.Join(dir, tlsClientFile),
+				"client_key_file":  filepath.Join(dir, tlsServerKeyFile),
+			},
+			summary: "^Invalid client certificate$",
+			detail:  "^Unable to use " + regexp.QuoteMeta(filepath.Join(dir, tlsClientFile)) + " and .+ as a client certificate and key: .*private key does not match public key$",
+		},
+		{
+			name:    "unreadable ca_cert_file",
+			config:  map[string]any{"api_url": server.URL, "ca_cert_file": filepath.Join(dir, "missing.pem")},
+			summary: "^Invalid CA certificate$",
+			detail:  "^Unable to use " + regexp.QuoteMeta(filepath.Join(dir, "missing.pem")) + " as a CA certificate: open .*",
+		},
+		{
+			name:    "ca_cert_file isn't PEM",
+			config:  map[string]any{"api_url": server.URL, "ca_cert_file": notPEM},
+			summary: "^Invalid CA certificate$",
+			detail:  "it doesn't contain any PEM encoded certificates$",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testProviderEnv(t)
+			_, diags := configureTestGRPCProvider(t, tc.config)
+			testExpectError(t, diags, tc.summary, tc.detail)
+		})
+	}
+
+	t.Run("insecure_skip_verify", func(t *testing.T) {
+		testProviderEnv(t)
+		p, diags := configureTestGRPCProvider(t, map[string]any{"api_url": server.URL, "insecure_skip_verify": true})
+		p.check("configure", diags)
+		if len(diags) != 1 || diags[0].Severity != tfprotov5.DiagnosticSeverityWarning ||
+			diags[0].Summary != "The mock API server's certificate isn't being verified" ||
+			!strings.HasPrefix(diags[0].Detail, "With `insecure_skip_verify` set, anyone in between") {
+			t.Fatalf("expected a warning about insecure_skip_verify, got %v", diags)
+		}
+	})
+
+	t.Run("configured correctly", func(t *testing.T) {
+		testProviderEnv(t)
+		newTestGRPCProvider(t, map[string]any{"api_url": server.URL, "ca_cert_file": ca})
+		newTestGRPCProvider(t, map[string]any{
+			"api_url":          mtls.URL,
+			"ca_cert_file":     ca,
+			"client_cert_file": filepath.Join(dir, tlsClientFile),
+			"client_key_file":  filepath.Join(dir, tlsClientKeyFile),
+		})
+	})
+}
@@ -0,0 +1,254 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The backend is a stand-in for the remote API a real provider would talk to.
+//
+// Terraform starts a brand new provider process for every command (plan,
+// apply, refresh etc) and so an in-memory store alone would lose everything
+// the moment `terraform apply` finished. To get around that the backend
+// persists its state into a JSON file on disk after every mutation and reloads
+// it before every operation.
+//
+// If no file path is configured, then the data only lives in memory for the
+// lifetime of the provider process (which is useful when experimenting with
+// the CRUD functions directly from Go code). It is still kept in its encoded
+// form though, so that a failed update can't leave half its changes behind.
+
+var (
+	// errNotFound is returned when the requested object doesn't exist.
+	errNotFound = errors.New("not found")
+
+	// errConflict is returned when the requested change clashes with the
+	// current state of the backend.
+	errConflict = errors.New("conflict")
+
+	// errInvalidParent is returned when a child references a parent that
+	// doesn't exist. It is distinct from errNotFound because it's the parent
+	// that is missing, not the object being operated on.
+	errInvalidParent = errors.New("invalid parent")
+
+	// errAlreadyExists is returned when creating an object whose name is
+	// already taken by another object of the same type.
+	errAlreadyExists = errors.New("already exists")
+)
+
+// alreadyExistsError says which object is already using a name. It unwraps
+// to errAlreadyExists.
+type alreadyExistsError struct {
+	kind string
+	name string
+	id   string
+}
+
+func (e *alreadyExistsError) Error() string {
+	return fmt.Sprintf("%s named %q %s (id: %s)", e.kind, e.name, errAlreadyExists, e.id)
+}
+
+func (e *alreadyExistsError) Unwrap() error {
+	return errAlreadyExists
+}
+
+// backendErrorCodes maps each of the errors above to a code, so that an
+// error can be recorded (e.g. against a failed operation) and later turned
+// back into something errors.Is understands.
+var backendErrorCodes = map[string]error{
+	"not_found":      errNotFound,
+	"conflict":       errConflict,
+	"invalid_parent": errInvalidParent,
+	"already_exists": errAlreadyExists,
+}
+
+// errorCode returns the code for err, or an empty string if err isn't one
+// of the backend's errors.
+func errorCode(err error) string {
+	for code, sentinel := range backendErrorCodes {
+		if errors.Is(err, sentinel) {
+			return code
+		}
+	}
+	return ""
+}
+
+// backend is a tiny persistent object store.
+type backend struct {
+	mu     sync.Mutex
+	path   string
+	memory []byte
+	async  asyncOptions
+}
+
+// backendState is everything the backend knows about. It is the structure
+// that gets serialised into the backend file.
+type backendState struct {
+	Counters       map[string]*counter       `json:"counters"`
+	CounterMembers map[string]*counterMember `json:"counter_members"`
+	Secrets        map[string]*secret        `json:"secrets"`
+	Parents        map[string]*parent        `json:"parents"`
+	Children       map[string]*child         `json:"children"`
+	Examples       map[string]*example       `json:"examples"`
+	AllTypes       map[string]*allTypes      `json:"all_types"`
+	Operations     map[string]*operation     `json:"operations"`
+	OperationSeq   int                       `json:"operation_seq"`
+}
+
+// newBackend returns a backend that persists its data to path. An empty path
+// keeps the data in memory only.
+func newBackend(path string) *backend {
+	return &backend{
+		path: path,
+	}
+}
+
+func newBackendState() *backendState {
+	return &backendState{
+		Counters:       make(map[string]*counter),
+		CounterMembers: make(map[string]*counterMember),
+		Secrets:        make(map[string]*secret),
+		Parents:        make(map[string]*parent),
+		Children:       make(map[string]*child),
+		Examples:       make(map[string]*example),
+		AllTypes:       make(map[string]*allTypes),
+		Operations:     make(map[string]*operation),
+	}
+}
+
+// view calls fn with the latest backend state. Any changes fn makes to the
+// state are discarded.
+func (b *backend) view(fn func(s *backendState) error) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := b.load()
+	if err != nil {
+		return err
+	}
+	return fn(s)
+}
+
+// update calls fn with the latest backend state and persists whatever changes
+// fn makes, unless fn returns an error.
+func (b *backend) update(fn func(s *backendState) error) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := b.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	return b.save(s)
+}
+
+// lock stops other goroutines, and other provider processes using the same
+// backend file, from reading and writing it until unlock is called.
+//
+// NOTE: Terraform runs a provider process for each provider block (and more
+// than one per command), and the backend file can be shared by several
+// terraform commands run at once. Without the lock file two processes could
+// both load the same revision of an object, both pass the revision check,
+// and the second to save would silently drop the change of the first.
+//
+// Like the lock file of a cassette, it's never removed, as removing it would
+// let two processes lock different files with the same name.
+func (b *backend) lock() (unlock func(), err error) {
+	b.mu.Lock()
+	if b.path == "" {
+		return b.mu.Unlock, nil
+	}
+	unlockFile, err := lockFile(b.path + ".lock")
+	if err != nil {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("failed to lock backend file %s: %w", b.path, err)
+	}
+	return func() {
+		unlockFile()
+		b.mu.Unlock()
+	}, nil
+}
+
+func (b *backend) load() (*backendState, error) {
+	// Decoding into a freshly initialised state means a file written by an
+	// older version of the provider still ends up with every map populated.
+	s := newBackendState()
+
+	data := b.memory
+	if b.path != "" {
+		var err error
+		data, err = os.ReadFile(b.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backend file %s: %w", b.path, err)
+		}
+	}
+	if data == nil {
+		return s, nil
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to decode backend file %s: %w", b.path, err)
+	}
+
+	// Time has passed since the state was last saved, so any asynchronous
+	// operations need to catch up before anyone looks at the state.
+	s.advanceOperations(time.Now())
+
+	return s, nil
+}
+
+func (b *backend) save(s *backendState) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backend state: %w", err)
+	}
+
+	if b.path == "" {
+		b.memory = data
+		return nil
+	}
+
+	if err := writeFileAtomic(b.path, data); err != nil {
+		return fmt.Errorf("failed to write backend file %s: %w", b.path, err)
+	}
+
+	log.Printf(">>> backend state saved to %s", b.path)
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path, so
+// that a crash half way through writing can't leave a corrupted file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
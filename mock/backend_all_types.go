@@ -0,0 +1,119 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+)
+
+// allTypes is the backend's representation of a mock_all_types resource. It
+// has a field for every attribute of the resource so that every one of them
+// makes the full round trip through the backend.
+type allTypes struct {
+	ID string `json:"id"`
+
+	BoolValue      bool    `json:"bool_value"`
+	FloatValue     float64 `json:"float_value"`
+	IntWithDefault int     `json:"int_with_default"`
+	StringValue    string  `json:"string_value,omitempty"`
+
+	StringMap map[string]string  `json:"string_map,omitempty"`
+	IntMap    map[string]int     `json:"int_map,omitempty"`
+	FloatMap  map[string]float64 `json:"float_map,omitempty"`
+	BoolMap   map[string]bool    `json:"bool_map,omitempty"`
+
+	StringSet []string           `json:"string_set,omitempty"`
+	IntSet    []int              `json:"int_set,omitempty"`
+	BlockSet  []allTypesKeyValue `json:"block_set,omitempty"`
+
+	Nested []allTypesNested `json:"nested,omitempty"`
+
+	OptionalComputed string `json:"optional_computed"`
+
+	ConflictsA    string `json:"conflicts_a,omitempty"`
+	ConflictsB    string `json:"conflicts_b,omitempty"`
+	ExactlyOneA   string `json:"exactly_one_a,omitempty"`
+	ExactlyOneB   string `json:"exactly_one_b,omitempty"`
+	AtLeastOneA   string `json:"at_least_one_a,omitempty"`
+	AtLeastOneB   string `json:"at_least_one_b,omitempty"`
+	RequiredWithA string `json:"required_with_a,omitempty"`
+	RequiredWithB string `json:"required_with_b,omitempty"`
+
+	LimitedList     []string `json:"limited_list,omitempty"`
+	DeprecatedValue string   `json:"deprecated_value,omitempty"`
+}
+
+type allTypesKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+type allTypesNested struct {
+	Name     string             `json:"name"`
+	LevelTwo []allTypesLevelTwo `json:"level_two,omitempty"`
+}
+
+type allTypesLevelTwo struct {
+	Name       string               `json:"name"`
+	LevelThree []allTypesLevelThree `json:"level_three,omitempty"`
+}
+
+type allTypesLevelThree struct {
+	Value string `json:"value"`
+}
+
+// allTypesOptionalComputedDefault is the value the backend uses for
+// optional_computed when it isn't given one.
+const allTypesOptionalComputedDefault = "assigned-by-backend"
+
+func (b *backend) createAllTypes(_ context.Context, a allTypes) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.AllTypes[a.ID]; ok {
+			return fmt.Errorf("%w: all_types %q already exists", errConflict, a.ID)
+		}
+		if a.OptionalComputed == "" {
+			a.OptionalComputed = allTypesOptionalComputedDefault
+		}
+		s.AllTypes[a.ID] = &a
+		return nil
+	})
+}
+
+func (b *backend) getAllTypes(_ context.Context, id string) (*allTypes, error) {
+	var a allTypes
+	err := b.view(func(s *backendState) error {
+		found, ok := s.AllTypes[id]
+		if !ok {
+			return fmt.Errorf("%w: all_types %q", errNotFound, id)
+		}
+		a = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (b *backend) updateAllTypes(_ context.Context, a allTypes) error {
+	return b.update(func(s *backendState) error {
+		prev, ok := s.AllTypes[a.ID]
+		if !ok {
+			return fmt.Errorf("%w: all_types %q", errNotFound, a.ID)
+		}
+		if a.OptionalComputed == "" {
+			a.OptionalComputed = prev.OptionalComputed
+		}
+		s.AllTypes[a.ID] = &a
+		return nil
+	})
+}
+
+func (b *backend) deleteAllTypes(_ context.Context, id string) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.AllTypes[id]; !ok {
+			return fmt.Errorf("%w: all_types %q", errNotFound, id)
+		}
+		delete(s.AllTypes, id)
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// counter is a named, monotonically increasing number.
+type counter struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// counterMember is an allocation of a single slot from a counter.
+type counterMember struct {
+	ID      string `json:"id"`
+	Counter string `json:"counter"`
+	Slot    int    `json:"slot"`
+}
+
+func (b *backend) createCounter(_ context.Context, c counter) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Counters[c.Name]; ok {
+			return &alreadyExistsError{kind: "counter", name: c.Name, id: c.Name}
+		}
+		s.Counters[c.Name] = &c
+		return nil
+	})
+}
+
+func (b *backend) getCounter(_ context.Context, name string) (*counter, error) {
+	var c counter
+	err := b.view(func(s *backendState) error {
+		found, ok := s.Counters[name]
+		if !ok {
+			return fmt.Errorf("%w: counter %q", errNotFound, name)
+		}
+		c = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// setCounterValue overwrites the value of a counter.
+//
+// NOTE:
+// There is deliberately no 'increment' operation. Like a lot of real APIs the
+// only way to bump the counter is to read it, add one, and write it back,
+// which is a race condition waiting to happen when several terraform
+// resources do it at the same time.
+func (b *backend) setCounterValue(_ context.Context, name string, value int) error {
+	return b.update(func(s *backendState) error {
+		c, ok := s.Counters[name]
+		if !ok {
+			return fmt.Errorf("%w: counter %q", errNotFound, name)
+		}
+		c.Value = value
+		return nil
+	})
+}
+
+func (b *backend) deleteCounter(_ context.Context, name string) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Counters[name]; !ok {
+			return fmt.Errorf("%w: counter %q", errNotFound, name)
+		}
+
+		// Like a parent with children, a counter can't be deleted while it
+		// has members, which would otherwise be left holding slots of a
+		// counter that doesn't exist.
+		var members []string
+		for _, m := range s.CounterMembers {
+			if m.Counter == name {
+				members = append(members, m.ID)
+			}
+		}
+		if len(members) > 0 {
+			sort.Strings(members)
+			return fmt.Errorf("%w: counter %q still has %d member(s): %s", errConflict, name, len(members), strings.Join(members, ", "))
+		}
+
+		delete(s.Counters, name)
+		return nil
+	})
+}
+
+// createCounterMember records a slot allocation. The backend refuses to hand
+// out the same slot twice, which is how a lost update from a race in the
+// provider becomes visible to the user.
+func (b *backend) createCounterMember(_ context.Context, m counterMember) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Counters[m.Counter]; !ok {
+			return fmt.Errorf("%w: counter %q", errNotFound, m.Counter)
+		}
+		for _, existing := range s.CounterMembers {
+			if existing.Counter == m.Counter && existing.Slot == m.Slot {
+				return fmt.Errorf("%w: slot %d of counter %q is already allocated to %s", errConflict, m.Slot, m.Counter, existing.ID)
+			}
+		}
+		s.CounterMembers[m.ID] = &m
+		return nil
+	})
+}
+
+func (b *backend) getCounterMember(_ context.Context, id string) (*counterMember, error) {
+	var m counterMember
+	err := b.view(func(s *backendState) error {
+		found, ok := s.CounterMembers[id]
+		if !ok {
+			return fmt.Errorf("%w: counter member %q", errNotFound, id)
+		}
+		m = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (b *backend) deleteCounterMember(_ context.Context, id string) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.CounterMembers[id]; !ok {
+			return fmt.Errorf("%w: counter member %q", errNotFound, id)
+		}
+		delete(s.CounterMembers, id)
+		return nil
+	})
+}
@@ -0,0 +1,392 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// example is the backend's representation of a mock_example resource.
+type example struct {
+	ID                  string            `json:"id"`
+	Name                string            `json:"name"`
+	NamePrefix          string            `json:"name_prefix,omitempty"`
+	NotComputedOptional string            `json:"not_computed_optional,omitempty"`
+	NotComputedRequired string            `json:"not_computed_required"`
+	Foo                 []exampleFoo      `json:"foo,omitempty"`
+	Baz                 []exampleBaz      `json:"baz"`
+	SomeList            []string          `json:"some_list,omitempty"`
+	Tags                map[string]string `json:"tags,omitempty"`
+	LastUpdated         string            `json:"last_updated"`
+
+	// Revision is incremented every time the object changes. An update must
+	// say which revision it was based on, and is rejected if the object has
+	// changed since (a.k.a. optimistic concurrency control, this is what an
+	// HTTP API would do with ETag and If-Match headers).
+	Revision int `json:"revision"`
+}
+
+type exampleFoo struct {
+	// Key identifies the entry among the object's other foo entries. It's
+	// optional, and entries without one are identified by their position.
+	Key string       `json:"key,omitempty"`
+	Bar []exampleBar `json:"bar"`
+}
+
+type exampleBar struct {
+	// Number is optional, and the backend assigns a default if it's nil
+	// (which isn't the same as 0).
+	Number  *int   `json:"number"`
+	Version string `json:"version"`
+}
+
+type exampleBaz struct {
+	Qux string `json:"qux"`
+}
+
+// clone returns a deep copy of e, which can be changed without changing e
+// (or whatever else shares its slices and maps).
+func (e *example) clone() example {
+	c := *e
+	c.Foo = cloneExampleFoo(e.Foo)
+	if e.Baz != nil {
+		c.Baz = append([]exampleBaz{}, e.Baz...)
+	}
+	if e.SomeList != nil {
+		c.SomeList = append([]string{}, e.SomeList...)
+	}
+	if e.Tags != nil {
+		c.Tags = make(map[string]string, len(e.Tags))
+		for k, v := range e.Tags {
+			c.Tags[k] = v
+		}
+	}
+	return c
+}
+
+// cloneExampleFoo returns a deep copy of foo. The bars are copied too, as
+// assignVersions and applyDefaults change them in place.
+func cloneExampleFoo(foo []exampleFoo) []exampleFoo {
+	if foo == nil {
+		return nil
+	}
+	c := make([]exampleFoo, len(foo))
+	for i, f := range foo {
+		c[i] = exampleFoo{Key: f.Key}
+		if f.Bar == nil {
+			continue
+		}
+		c[i].Bar = make([]exampleBar, len(f.Bar))
+		for j, b := range f.Bar {
+			c[i].Bar[j] = exampleBar{Version: b.Version}
+			if b.Number != nil {
+				n := *b.Number
+				c[i].Bar[j].Number = &n
+			}
+		}
+	}
+	return c
+}
+
+// assignVersions gives every bar a version. A bar keeps the version it had in
+// prev if its number hasn't changed, otherwise it gets a new one.
+func (e *example) assignVersions(prev *example) {
+	for i := range e.Foo {
+		var prevFoo *exampleFoo
+		if prev != nil {
+			prevFoo = prev.findFoo(i, e.Foo[i].Key)
+		}
+		for j := range e.Foo[i].Bar {
+			bar := &e.Foo[i].Bar[j]
+			if prevFoo != nil && j < len(prevFoo.Bar) {
+				if old := prevFoo.Bar[j]; sameNumber(old.Number, bar.Number) {
+					bar.Version = old.Version
+					continue
+				}
+			}
+			bar.Version = uuid.New().String()
+		}
+	}
+}
+
+// sameNumber reports whether two optional numbers are the same.
+func sameNumber(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Like most real APIs, the backend fills in a default for any optional
+// attribute that isn't given a value.
+const (
+	defaultNotComputedOptional = "backend-default"
+	defaultBarNumber           = 10
+)
+
+// applyDefaults fills in the defaults of any optional attributes that are
+// missing.
+func (e *example) applyDefaults() {
+	if e.NotComputedOptional == "" {
+		e.NotComputedOptional = defaultNotComputedOptional
+	}
+	for i := range e.Foo {
+		for j := range e.Foo[i].Bar {
+			if e.Foo[i].Bar[j].Number == nil {
+				n := defaultBarNumber
+				e.Foo[i].Bar[j].Number = &n
+			}
+		}
+	}
+}
+
+// findFoo returns the foo entry with the given key or, for entries without a
+// key, at the given position.
+func (e *example) findFoo(i int, key string) *exampleFoo {
+	if key == "" {
+		if i < len(e.Foo) && e.Foo[i].Key == "" {
+			return &e.Foo[i]
+		}
+		return nil
+	}
+	for k := range e.Foo {
+		if e.Foo[k].Key == key {
+			return &e.Foo[k]
+		}
+	}
+	return nil
+}
+
+// checkExampleName returns an error if name is already used by another
+// example, including any that are still in the process of being created.
+func (s *backendState) checkExampleName(name string) error {
+	for _, existing := range s.Examples {
+		if existing.Name == name {
+			return &alreadyExistsError{kind: "example", name: name, id: existing.ID}
+		}
+	}
+	for _, op := range s.Operations {
+		if op.Kind == "create" && !op.done() && op.Example.Name == name {
+			return &alreadyExistsError{kind: "example", name: name, id: op.Target}
+		}
+	}
+	return nil
+}
+
+// defaultNamePrefix is used to generate a name for an example that was
+// created without a name or a name_prefix.
+const defaultNamePrefix = "terraform-"
+
+// generateExampleName returns a name starting with prefix that isn't used by
+// any other example.
+func (s *backendState) generateExampleName(prefix string) string {
+	for {
+		name := prefix + strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+		if s.checkExampleName(name) == nil {
+			return name
+		}
+	}
+}
+
+// createExample queues the creation of e. If e.Name is empty, the backend
+// generates a unique name starting with e.NamePrefix. The returned
+// operation's Target is the ID the new object will have.
+func (b *backend) createExample(_ context.Context, e example) (*operation, error) {
+	var op *operation
+	err := b.update(func(s *backendState) error {
+		// If we've only been given a prefix, then it's up to us to come up
+		// with a name that isn't already taken.
+		if e.Name == "" {
+			if e.NamePrefix == "" {
+				e.NamePrefix = defaultNamePrefix
+			}
+			e.Name = s.generateExampleName(e.NamePrefix)
+		}
+		if err := s.checkExampleName(e.Name); err != nil {
+			return err
+		}
+
+		e.ID = uuid.New().String()
+		e.Revision = 1
+		e.LastUpdated = time.Now().Format(time.RFC850)
+		e.applyDefaults()
+		e.assignVersions(nil)
+
+		op = b.queueOperation(s, &operation{
+			Kind:    "create",
+			Target:  e.ID,
+			Example: &e,
+		})
+		return nil
+	})
+	return op, err
+}
+
+func (b *backend) getExample(_ context.Context, id string) (*example, error) {
+	var e example
+	err := b.view(func(s *backendState) error {
+		found, ok := s.Examples[id]
+		if !ok {
+			return fmt.Errorf("%w: example %q", errNotFound, id)
+		}
+		e = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// checkRevision returns a conflict error if current isn't at the given
+// revision.
+func checkRevision(current *example, revision int) error {
+	if current.Revision != revision {
+		return fmt.Errorf(
+			"%w: example %q is at revision %d but the update was based on revision %d",
+			errConflict, current.ID, current.Revision, revision,
+		)
+	}
+	return nil
+}
+
+// patchExample queues an update that only changes part of the object with the
+// given ID, as made by patch, and leaves the rest of it alone.
+//
+// revision must be the revision of the object the caller last read. If the
+// object has been changed since, the update is rejected with errConflict.
+//
+// NOTE: The backend has no call that replaces the whole object, each part of
+// it has its own (setExampleFields, setExampleFoo, etc). Like a lot of real
+// APIs, that means an update made up of several calls can fail part way.
+func (b *backend) patchExample(id string, revision int, patch func(e *example) error) (*operation, error) {
+	var op *operation
+	err := b.update(func(s *backendState) error {
+		prev, ok := s.Examples[id]
+		if !ok {
+			return fmt.Errorf("%w: example %q", errNotFound, id)
+		}
+		if err := checkRevision(prev, revision); err != nil {
+			return err
+		}
+
+		// patch works on a deep copy, so that the stored object (which
+		// assignVersions compares against) is left exactly as it was.
+		e := prev.clone()
+		if err := patch(&e); err != nil {
+			return err
+		}
+		e.Revision = prev.Revision + 1
+		e.LastUpdated = time.Now().Format(time.RFC850)
+		e.applyDefaults()
+		e.assignVersions(prev)
+
+		op = b.queueOperation(s, &operation{
+			Kind:    "update",
+			Target:  id,
+			Example: &e,
+		})
+		return nil
+	})
+	return op, err
+}
+
+// setExampleFields queues the replacement of the top-level attributes of an
+// example (i.e. everything but its nested blocks and lists) with those of e.
+func (b *backend) setExampleFields(_ context.Context, id string, revision int, e example) (*operation, error) {
+	return b.patchExample(id, revision, func(current *example) error {
+		current.NotComputedOptional = e.NotComputedOptional
+		current.NotComputedRequired = e.NotComputedRequired
+		current.Tags = e.Tags
+		return nil
+	})
+}
+
+// setExampleFoo queues the replacement of the foo entries of an example.
+func (b *backend) setExampleFoo(_ context.Context, id string, revision int, foo []exampleFoo) (*operation, error) {
+	return b.patchExample(id, revision, func(e *example) error {
+		e.Foo = cloneExampleFoo(foo)
+		return nil
+	})
+}
+
+// fooPatchOp is a change to a single foo entry, in the style of a JSON Patch
+// (RFC 6902) operation, e.g. {"op": "replace", "path": "/foo/1", "value": ...}.
+//
+// As with JSON Patch, the ops in a patch are applied in order and each op's
+// index refers to the list as it is after the ops before it.
+type fooPatchOp struct {
+	Op    string      `json:"op"` // "add", "remove" or "replace"
+	Index int         `json:"index"`
+	Value *exampleFoo `json:"value,omitempty"`
+}
+
+// String returns the op as it would appear in a JSON Patch.
+func (op fooPatchOp) String() string {
+	return fmt.Sprintf("%s /foo/%d", op.Op, op.Index)
+}
+
+// patchExampleFoo queues the changes to individual foo entries of an example
+// described by ops. Unlike setExampleFoo, entries that aren't mentioned keep
+// whatever they are in the backend.
+func (b *backend) patchExampleFoo(_ context.Context, id string, revision int, ops []fooPatchOp) (*operation, error) {
+	return b.patchExample(id, revision, func(e *example) error {
+		// e is already a copy, but the entries in ops belong to the caller.
+		foo := e.Foo
+		for _, op := range ops {
+			var value []exampleFoo
+			if op.Value != nil {
+				value = cloneExampleFoo([]exampleFoo{*op.Value})
+			}
+			switch {
+			case op.Op == "add" && op.Index <= len(foo) && value != nil:
+				foo = append(foo[:op.Index], append(value, foo[op.Index:]...)...)
+			case op.Op == "remove" && op.Index < len(foo):
+				foo = append(foo[:op.Index], foo[op.Index+1:]...)
+			case op.Op == "replace" && op.Index < len(foo) && value != nil:
+				foo[op.Index] = value[0]
+			default:
+				return fmt.Errorf("invalid patch of example %q: %s (there are %d foo entries)", id, op, len(foo))
+			}
+		}
+		e.Foo = foo
+		return nil
+	})
+}
+
+// setExampleBaz queues the replacement of the baz settings of an example.
+func (b *backend) setExampleBaz(_ context.Context, id string, revision int, baz []exampleBaz) (*operation, error) {
+	return b.patchExample(id, revision, func(e *example) error {
+		e.Baz = baz
+		return nil
+	})
+}
+
+// setExampleSomeList queues the replacement of the some_list items of an
+// example.
+func (b *backend) setExampleSomeList(_ context.Context, id string, revision int, items []string) (*operation, error) {
+	return b.patchExample(id, revision, func(e *example) error {
+		e.SomeList = items
+		return nil
+	})
+}
+
+// deleteExample queues the deletion of the object with the given ID.
+func (b *backend) deleteExample(_ context.Context, id string) (*operation, error) {
+	var op *operation
+	err := b.update(func(s *backendState) error {
+		if _, ok := s.Examples[id]; !ok {
+			return fmt.Errorf("%w: example %q", errNotFound, id)
+		}
+
+		op = b.queueOperation(s, &operation{
+			Kind:   "delete",
+			Target: id,
+		})
+		return nil
+	})
+	return op, err
+}
@@ -0,0 +1,140 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPatchExampleLeavesStoredObjectAlone(t *testing.T) {
+	ctx := context.Background()
+	b := newBackend("")
+
+	number := 1
+	op, err := b.createExample(ctx, example{
+		Name:                "patch",
+		NotComputedRequired: "required",
+		Foo: []exampleFoo{
+			{Key: "a", Bar: []exampleBar{{Number: &number}}},
+			{Key: "b", Bar: []exampleBar{{Number: &number}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := b.getExample(ctx, op.Target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With async operations, the stored object has to stay as it was until
+	// the update's operation is done.
+	b.async = asyncOptions{Enabled: true, PendingDuration: time.Hour}
+	_, err = b.patchExample(before.ID, before.Revision, func(e *example) error {
+		two := 2
+		e.Foo[0].Bar[0].Number = &two
+		*e.Foo[1].Bar[0].Number = 3
+		e.Foo[1].Bar[0].Version = "changed"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := b.getExample(ctx, before.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Revision != before.Revision {
+		t.Errorf("expected revision %d until the update is done, got %d", before.Revision, after.Revision)
+	}
+	for i := range before.Foo {
+		got, want := after.Foo[i].Bar[0], before.Foo[i].Bar[0]
+		if *got.Number != *want.Number || got.Version != want.Version {
+			t.Errorf("foo %d changed before the update was done: number %d -> %d, version %s -> %s", i, *want.Number, *got.Number, want.Version, got.Version)
+		}
+	}
+}
+
+func TestPatchExampleFooCopiesOps(t *testing.T) {
+	ctx := context.Background()
+	b := newBackend("")
+
+	op, err := b.createExample(ctx, example{
+		Name:                "patch",
+		NotComputedRequired: "required",
+		Foo:                 []exampleFoo{{Key: "a", Bar: []exampleBar{{}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend fills in the number and version of the new entry, which
+	// mustn't change the caller's copy of it.
+	value := &exampleFoo{Key: "b", Bar: []exampleBar{{}}}
+	if _, err := b.patchExampleFoo(ctx, op.Target, 1, []fooPatchOp{{Op: "add", Index: 1, Value: value}}); err != nil {
+		t.Fatal(err)
+	}
+	if bar := value.Bar[0]; bar.Number != nil || bar.Version != "" {
+		t.Errorf("the patch op was changed: %+v", bar)
+	}
+
+	e, err := b.getExample(ctx, op.Target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Foo) != 2 || e.Foo[1].Key != "b" || e.Foo[1].Bar[0].Number == nil || *e.Foo[1].Bar[0].Number != defaultBarNumber {
+		t.Errorf("expected the new entry to be added with the default number, got %+v", e.Foo)
+	}
+}
+
+func TestSetExampleFieldsLocksBackendFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "backend.json")
+
+	op, err := newBackend(path).createExample(ctx, example{Name: "locked", NotComputedRequired: "required"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each backend has a mutex of its own, like the backend of each provider
+	// process, so only the lock file stops two of them from both updating
+	// revision 1.
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := newBackend(path).setExampleFields(ctx, op.Target, 1, example{NotComputedRequired: fmt.Sprint(i)})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var won int
+	for err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, errConflict):
+			t.Fatal(err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("expected exactly one update of revision 1 to succeed, got %d", won)
+	}
+	got, err := newBackend(path).getExample(ctx, op.Target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Revision != 2 {
+		t.Errorf("expected the example to be at revision 2, got %d", got.Revision)
+	}
+}
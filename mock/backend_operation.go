@@ -0,0 +1,193 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Real cloud APIs rarely complete a create or delete within the lifetime of
+// the HTTP request. Instead they return a handle to a long-running
+// 'operation' that the client has to poll until it's finished.
+//
+// When async mode is enabled, the backend behaves the same way: mutations are
+// queued as an operation that moves from PENDING to RUNNING and then finally
+// to DONE (at which point the change becomes visible) or FAILED (in which case
+// the change is discarded).
+//
+// Operations progress purely based on the time elapsed since they were
+// created, which means they keep progressing across provider processes
+// without anything running in the background.
+
+const (
+	operationPending = "PENDING"
+	operationRunning = "RUNNING"
+	operationDone    = "DONE"
+	operationFailed  = "FAILED"
+)
+
+// operationRetention is how long finished operations are kept around for so
+// that a client can still poll them.
+const operationRetention = time.Hour
+
+// asyncOptions controls how the backend processes mutations.
+type asyncOptions struct {
+	// Enabled makes mutations asynchronous. When false every operation is
+	// DONE by the time it's returned.
+	Enabled bool
+
+	// PendingDuration is how long an operation stays PENDING.
+	PendingDuration time.Duration
+
+	// RunningDuration is how long an operation stays RUNNING once it's no
+	// longer PENDING.
+	RunningDuration time.Duration
+
+	// FailureRate is the probability (0 to 1) of an operation ending up
+	// FAILED instead of DONE.
+	FailureRate float64
+}
+
+// operation is a queued mutation of a backend object.
+type operation struct {
+	ID     string `json:"id"`
+	Seq    int    `json:"seq"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+
+	// Error and ErrorCode describe why a FAILED operation failed.
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+
+	CreatedAt    time.Time `json:"created_at"`
+	PendingUntil time.Time `json:"pending_until"`
+	RunningUntil time.Time `json:"running_until"`
+
+	// Fail is decided up front so the outcome of the operation doesn't
+	// depend on when (or how often) it is polled.
+	Fail bool `json:"fail,omitempty"`
+
+	// Example is the object to store when a create/update operation
+	// completes.
+	Example *example `json:"example,omitempty"`
+}
+
+// done reports whether the operation has finished, successfully or not.
+func (o *operation) done() bool {
+	return o.Status == operationDone || o.Status == operationFailed
+}
+
+// queueOperation records a new operation in s and immediately advances it,
+// which for a synchronous backend means it's applied straight away.
+func (b *backend) queueOperation(s *backendState, op *operation) *operation {
+	now := time.Now()
+
+	s.OperationSeq++
+	op.ID = uuid.New().String()
+	op.Seq = s.OperationSeq
+	op.Status = operationPending
+	op.CreatedAt = now
+	op.PendingUntil = now
+	op.RunningUntil = now
+
+	if b.async.Enabled {
+		op.PendingUntil = now.Add(b.async.PendingDuration)
+		op.RunningUntil = op.PendingUntil.Add(b.async.RunningDuration)
+		op.Fail = rand.Float64() < b.async.FailureRate
+	}
+
+	s.Operations[op.ID] = op
+	s.advanceOperations(now)
+
+	cp := *op
+	return &cp
+}
+
+// advanceOperations moves every unfinished operation along to the status it
+// should have at the given time, applying the changes of any that complete.
+func (s *backendState) advanceOperations(now time.Time) {
+	var ops []*operation
+	for id, op := range s.Operations {
+		if op.done() && now.Sub(op.RunningUntil) > operationRetention {
+			delete(s.Operations, id)
+			continue
+		}
+		if !op.done() {
+			ops = append(ops, op)
+		}
+	}
+
+	// Operations must complete in the order they were queued, otherwise an
+	// update could be applied before the create it depends on.
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Seq < ops[j].Seq })
+
+	for _, op := range ops {
+		switch {
+		case !now.Before(op.RunningUntil):
+			s.completeOperation(op)
+		case !now.Before(op.PendingUntil):
+			op.Status = operationRunning
+		}
+	}
+}
+
+func (s *backendState) completeOperation(op *operation) {
+	if op.Fail {
+		op.Status = operationFailed
+		op.Error = fmt.Sprintf("%s of %s failed (simulated failure)", op.Kind, op.Target)
+		return
+	}
+
+	if err := s.applyOperation(op); err != nil {
+		op.Status = operationFailed
+		op.Error = err.Error()
+		op.ErrorCode = errorCode(err)
+		return
+	}
+	op.Status = operationDone
+}
+
+func (s *backendState) applyOperation(op *operation) error {
+	switch op.Kind {
+	case "update":
+		current, ok := s.Examples[op.Target]
+		if !ok {
+			return fmt.Errorf("%w: example %q", errNotFound, op.Target)
+		}
+		// Another update may have completed since this one was queued, in
+		// which case this one is based on a stale revision.
+		if err := checkRevision(current, op.Example.Revision-1); err != nil {
+			return err
+		}
+		fallthrough
+	case "create":
+		e := op.Example.clone()
+		s.Examples[op.Target] = &e
+	case "delete":
+		delete(s.Examples, op.Target)
+	default:
+		return fmt.Errorf("unknown operation kind %q", op.Kind)
+	}
+	return nil
+}
+
+func (b *backend) getOperation(_ context.Context, id string) (*operation, error) {
+	var op operation
+	err := b.view(func(s *backendState) error {
+		found, ok := s.Operations[id]
+		if !ok {
+			return fmt.Errorf("%w: operation %q", errNotFound, id)
+		}
+		op = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
@@ -0,0 +1,173 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parent and child model a pair of objects with a foreign key between them.
+//
+// The backend enforces referential integrity in the same way a relational
+// database (or most real APIs) would:
+//
+//   - a child can't be created for a parent that doesn't exist.
+//   - a parent can't be deleted while it still has children.
+//
+// This means terraform must create parents before children and destroy
+// children before parents, which it only knows to do if the dependency is
+// visible in the configuration (e.g. `parent_id = mock_parent.p.id`).
+type parent struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type child struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+// checkParentName returns an error if another parent is already called p.Name.
+func (s *backendState) checkParentName(p parent) error {
+	for _, existing := range s.Parents {
+		if existing.Name == p.Name && existing.ID != p.ID {
+			return &alreadyExistsError{kind: "parent", name: p.Name, id: existing.ID}
+		}
+	}
+	return nil
+}
+
+// checkChildName returns an error if another child is already called c.Name.
+func (s *backendState) checkChildName(c child) error {
+	for _, existing := range s.Children {
+		if existing.Name == c.Name && existing.ID != c.ID {
+			return &alreadyExistsError{kind: "child", name: c.Name, id: existing.ID}
+		}
+	}
+	return nil
+}
+
+func (b *backend) createParent(_ context.Context, p parent) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Parents[p.ID]; ok {
+			return fmt.Errorf("%w: parent %q already exists", errConflict, p.ID)
+		}
+		if err := s.checkParentName(p); err != nil {
+			return err
+		}
+		s.Parents[p.ID] = &p
+		return nil
+	})
+}
+
+func (b *backend) getParent(_ context.Context, id string) (*parent, error) {
+	var p parent
+	err := b.view(func(s *backendState) error {
+		found, ok := s.Parents[id]
+		if !ok {
+			return fmt.Errorf("%w: parent %q", errNotFound, id)
+		}
+		p = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (b *backend) updateParent(_ context.Context, p parent) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Parents[p.ID]; !ok {
+			return fmt.Errorf("%w: parent %q", errNotFound, p.ID)
+		}
+		if err := s.checkParentName(p); err != nil {
+			return err
+		}
+		s.Parents[p.ID] = &p
+		return nil
+	})
+}
+
+// deleteParent refuses to delete a parent that still has children.
+func (b *backend) deleteParent(_ context.Context, id string) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Parents[id]; !ok {
+			return fmt.Errorf("%w: parent %q", errNotFound, id)
+		}
+
+		var children []string
+		for _, c := range s.Children {
+			if c.ParentID == id {
+				children = append(children, c.ID)
+			}
+		}
+		if len(children) > 0 {
+			sort.Strings(children)
+			return fmt.Errorf("%w: parent %q still has %d child(ren): %s", errConflict, id, len(children), strings.Join(children, ", "))
+		}
+
+		delete(s.Parents, id)
+		return nil
+	})
+}
+
+func (b *backend) createChild(_ context.Context, c child) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Parents[c.ParentID]; !ok {
+			return fmt.Errorf("%w: parent %q does not exist", errInvalidParent, c.ParentID)
+		}
+		if _, ok := s.Children[c.ID]; ok {
+			return fmt.Errorf("%w: child %q already exists", errConflict, c.ID)
+		}
+		if err := s.checkChildName(c); err != nil {
+			return err
+		}
+		s.Children[c.ID] = &c
+		return nil
+	})
+}
+
+func (b *backend) getChild(_ context.Context, id string) (*child, error) {
+	var c child
+	err := b.view(func(s *backendState) error {
+		found, ok := s.Children[id]
+		if !ok {
+			return fmt.Errorf("%w: child %q", errNotFound, id)
+		}
+		c = *found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (b *backend) updateChild(_ context.Context, c child) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Children[c.ID]; !ok {
+			return fmt.Errorf("%w: child %q", errNotFound, c.ID)
+		}
+		if _, ok := s.Parents[c.ParentID]; !ok {
+			return fmt.Errorf("%w: parent %q does not exist", errInvalidParent, c.ParentID)
+		}
+		if err := s.checkChildName(c); err != nil {
+			return err
+		}
+		s.Children[c.ID] = &c
+		return nil
+	})
+}
+
+func (b *backend) deleteChild(_ context.Context, id string) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Children[id]; !ok {
+			return fmt.Errorf("%w: child %q", errNotFound, id)
+		}
+		delete(s.Children, id)
+		return nil
+	})
+}
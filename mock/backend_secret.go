@@ -0,0 +1,126 @@
+package mock
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// secret is a write-only value along with an API key generated by the
+// backend.
+//
+// Like a real secrets API, the backend never hands the value back once it has
+// been written. The best a client can get is a hash of it, which is enough to
+// notice that it was changed by someone else.
+type secret struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	APIKey string `json:"api_key"`
+
+	// ValueHash is only populated on the copies returned to callers.
+	ValueHash string `json:"value_hash,omitempty"`
+}
+
+// hashSecretValue is the hash the backend exposes in place of a secret value.
+func hashSecretValue(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a new random API key.
+func generateAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return "mock_" + hex.EncodeToString(b), nil
+}
+
+// redacted returns a copy of the secret that is safe to hand back to a caller.
+func (s secret) redacted() *secret {
+	s.ValueHash = hashSecretValue(s.Value)
+	s.Value = ""
+	return &s
+}
+
+func (b *backend) createSecret(_ context.Context, sec secret) (*secret, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	sec.APIKey = key
+
+	err = b.update(func(s *backendState) error {
+		if _, ok := s.Secrets[sec.ID]; ok {
+			return fmt.Errorf("%w: secret %q already exists", errConflict, sec.ID)
+		}
+		for _, existing := range s.Secrets {
+			if existing.Name == sec.Name {
+				return &alreadyExistsError{kind: "secret", name: sec.Name, id: existing.ID}
+			}
+		}
+		s.Secrets[sec.ID] = &sec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sec.redacted(), nil
+}
+
+func (b *backend) getSecret(_ context.Context, id string) (*secret, error) {
+	var sec *secret
+	err := b.view(func(s *backendState) error {
+		found, ok := s.Secrets[id]
+		if !ok {
+			return fmt.Errorf("%w: secret %q", errNotFound, id)
+		}
+		sec = found.redacted()
+		return nil
+	})
+	return sec, err
+}
+
+func (b *backend) setSecretValue(_ context.Context, id, value string) error {
+	return b.update(func(s *backendState) error {
+		sec, ok := s.Secrets[id]
+		if !ok {
+			return fmt.Errorf("%w: secret %q", errNotFound, id)
+		}
+		sec.Value = value
+		return nil
+	})
+}
+
+// rotateSecretAPIKey replaces the secret's API key with a new one.
+func (b *backend) rotateSecretAPIKey(_ context.Context, id string) (*secret, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var sec *secret
+	err = b.update(func(s *backendState) error {
+		found, ok := s.Secrets[id]
+		if !ok {
+			return fmt.Errorf("%w: secret %q", errNotFound, id)
+		}
+		found.APIKey = key
+		sec = found.redacted()
+		return nil
+	})
+	return sec, err
+}
+
+func (b *backend) deleteSecret(_ context.Context, id string) error {
+	return b.update(func(s *backendState) error {
+		if _, ok := s.Secrets[id]; !ok {
+			return fmt.Errorf("%w: secret %q", errNotFound, id)
+		}
+		delete(s.Secrets, id)
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package mock
+
+import "context"
+
+const (
+	// conflictPolicyError reports a conflicting update to the user.
+	conflictPolicyError = "error"
+
+	// conflictPolicyRefreshAndRetry fetches the latest revision of the object
+	// and applies the update again.
+	conflictPolicyRefreshAndRetry = "refresh_and_retry"
+
+	// maxConflictRetries is how many times an update is attempted under the
+	// refresh_and_retry policy.
+	maxConflictRetries = 3
+)
+
+// Client is what the provider's ConfigureContextFunc returns, and so it's the
+// value every CRUD function receives as its 'meta' parameter.
+//
+// In a real provider this would be the API client (e.g. *fastly.Client), but
+// for our mock provider it wraps the backend along with any other
+// provider-level state that needs sharing between resources.
+type Client struct {
+	// backend is either the local backend itself, or an *apiClient talking
+	// to a mock API server (see 'api_url').
+	backend backendAPI
+
+	// mutexes lets CRUD functions running in parallel serialise access to a
+	// shared remote object (see mutexKV for the details).
+	mutexes *mutexKV
+
+	// lockingDisabled turns the mutexes into no-ops so the race conditions
+	// they protect against can be reproduced.
+	lockingDisabled bool
+
+	// conflictPolicy decides what happens when an update is rejected because
+	// the object changed since it was last read.
+	conflictPolicy string
+
+	// defaultTags are merged into the tags of every resource that supports
+	// them (see tags.go).
+	defaultTags map[string]string
+
+	// strictConsistency stops terraform from tolerating inconsistent results
+	// (see inconsistency.go).
+	strictConsistency bool
+}
+
+// lock acquires the provider-level mutex for key and returns a function that
+// releases it, so callers can simply `defer c.lock(key)()`.
+func (c *Client) lock(key string) func() {
+	if c.lockingDisabled {
+		return func() {}
+	}
+	c.mutexes.Lock(key)
+	return func() { c.mutexes.Unlock(key) }
+}
+
+// backendAPI is everything the CRUD functions can ask of the backend. It's
+// implemented by *backend, which does the work in-process, and by *apiClient,
+// which sends each call to the mock API server over HTTP.
+type backendAPI interface {
+	createCounter(ctx context.Context, c counter) error
+	getCounter(ctx context.Context, name string) (*counter, error)
+	setCounterValue(ctx context.Context, name string, value int) error
+	deleteCounter(ctx context.Context, name string) error
+
+	createCounterMember(ctx context.Context, m counterMember) error
+	getCounterMember(ctx context.Context, id string) (*counterMember, error)
+	deleteCounterMember(ctx context.Context, id string) error
+
+	createSecret(ctx context.Context, sec secret) (*secret, error)
+	getSecret(ctx context.Context, id string) (*secret, error)
+	setSecretValue(ctx context.Context, id, value string) error
+	rotateSecretAPIKey(ctx context.Context, id string) (*secret, error)
+	deleteSecret(ctx context.Context, id string) error
+
+	createParent(ctx context.Context, p parent) error
+	getParent(ctx context.Context, id string) (*parent, error)
+	updateParent(ctx context.Context, p parent) error
+	deleteParent(ctx context.Context, id string) error
+
+	createChild(ctx context.Context, c child) error
+	getChild(ctx context.Context, id string) (*child, error)
+	updateChild(ctx context.Context, c child) error
+	deleteChild(ctx context.Context, id string) error
+
+	createAllTypes(ctx context.Context, a allTypes) error
+	getAllTypes(ctx context.Context, id string) (*allTypes, error)
+	updateAllTypes(ctx context.Context, a allTypes) error
+	deleteAllTypes(ctx context.Context, id string) error
+
+	createExample(ctx context.Context, e example) (*operation, error)
+	getExample(ctx context.Context, id string) (*example, error)
+	setExampleFields(ctx context.Context, id string, revision int, e example) (*operation, error)
+	setExampleFoo(ctx context.Context, id string, revision int, foo []exampleFoo) (*operation, error)
+	patchExampleFoo(ctx context.Context, id string, revision int, ops []fooPatchOp) (*operation, error)
+	setExampleBaz(ctx context.Context, id string, revision int, baz []exampleBaz) (*operation, error)
+	setExampleSomeList(ctx context.Context, id string, revision int, items []string) (*operation, error)
+	deleteExample(ctx context.Context, id string) (*operation, error)
+
+	getOperation(ctx context.Context, id string) (*operation, error)
+}
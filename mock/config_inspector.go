@@ -0,0 +1,192 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The config inspector (available as both a resource and a data source)
+// reports what the provider actually sees for each of its input attributes.
+//
+// The SDK gives you several ways of looking at an attribute and they don't
+// agree with each other:
+//
+//   - d.Get returns the zero value ("", 0, false, empty list) when the
+//     attribute isn't set, so it can't tell 'unset' from 'set to zero'.
+//   - d.GetOk returns ok=false for unset attributes, but also for ones set to
+//     their zero value.
+//   - d.GetOkExists is meant to tell 'set to zero' from 'unset', but only
+//     really works for primitives (and is deprecated as a result).
+//   - d.GetRawConfig returns the configuration exactly as terraform sent it,
+//     as a cty.Value, which knows whether a value is null, unknown or set.
+//   - d.GetRawPlan returns the planned new state, which is the configuration
+//     merged with any defaults and computed values.
+
+// configInspectorInputs are the attributes being inspected.
+func configInspectorInputs() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"string_value": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "A string to inspect.",
+		},
+		"int_value": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "A number to inspect.",
+		},
+		"bool_value": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "A boolean to inspect.",
+		},
+		"list_value": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "A list of strings to inspect.",
+		},
+		"map_value": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "A map of strings to inspect.",
+		},
+		"block": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "A nested block to inspect.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"value": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "A string inside the block.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// configInspectionSchema is the schema of the computed attribute holding the
+// result of an inspection.
+func configInspectionSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: description,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"attribute": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "The name of the attribute inspected.",
+				},
+				"raw_config": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "What GetRawConfig says about the attribute: `null`, `unknown`, `partially_unknown`, `empty` or `set`.",
+				},
+				"raw_plan": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "What GetRawPlan says about the attribute (same values as `raw_config`).",
+				},
+				"get": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "The value returned by Get, formatted with `%#v`.",
+				},
+				"get_ok": {
+					Type:        schema.TypeBool,
+					Computed:    true,
+					Description: "The `ok` value returned by GetOk.",
+				},
+				"get_ok_exists": {
+					Type:        schema.TypeBool,
+					Computed:    true,
+					Description: "The `ok` value returned by GetOkExists.",
+				},
+			},
+		},
+	}
+}
+
+// configInspectable is implemented by both *schema.ResourceData (available in
+// CRUD functions) and *schema.ResourceDiff (available in CustomizeDiff).
+type configInspectable interface {
+	Get(key string) any
+	GetOk(key string) (any, bool)
+	GetOkExists(key string) (any, bool)
+	GetRawConfig() cty.Value
+	GetRawPlan() cty.Value
+}
+
+// inspectConfig inspects every input attribute, returning the result in a
+// form that can be given to d.Set/d.SetNew.
+func inspectConfig(d configInspectable) []any {
+	var names []string
+	for name := range configInspectorInputs() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rawConfig := d.GetRawConfig()
+	rawPlan := d.GetRawPlan()
+
+	result := make([]any, 0, len(names))
+	for _, name := range names {
+		_, getOk := d.GetOk(name)
+		//lint:ignore SA1019 we're demonstrating how it behaves
+		_, getOkExists := d.GetOkExists(name) //nolint:staticcheck
+
+		result = append(result, map[string]any{
+			"attribute":     name,
+			"raw_config":    describeRawAttribute(rawConfig, name),
+			"raw_plan":      describeRawAttribute(rawPlan, name),
+			"get":           fmt.Sprintf("%#v", d.Get(name)),
+			"get_ok":        getOk,
+			"get_ok_exists": getOkExists,
+		})
+	}
+	return result
+}
+
+// describeRawAttribute describes the named attribute of a raw config/plan.
+func describeRawAttribute(raw cty.Value, name string) string {
+	// The whole object is null when there's no config/plan at all (e.g. the
+	// raw plan of a data source).
+	if raw.IsNull() {
+		return "null"
+	}
+	if !raw.IsKnown() {
+		return "unknown"
+	}
+	if !raw.Type().IsObjectType() || !raw.Type().HasAttribute(name) {
+		return "null"
+	}
+
+	v := raw.GetAttr(name)
+	switch {
+	case !v.IsKnown():
+		// e.g. `string_value = some_resource.other.computed_attribute` where
+		// some_resource.other hasn't been created yet.
+		return "unknown"
+	case v.IsNull():
+		return "null"
+	case !v.IsWhollyKnown():
+		// e.g. a list where some of the elements are unknown.
+		return "partially_unknown"
+	case v.Type() == cty.String && v.AsString() == "":
+		return "empty"
+	case (v.Type().IsListType() || v.Type().IsMapType() || v.Type().IsSetType()) && v.LengthInt() == 0:
+		return "empty"
+	default:
+		return "set"
+	}
+}
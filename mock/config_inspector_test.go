@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestDescribeRawAttribute(t *testing.T) {
+	cases := map[string]struct {
+		value cty.Value
+		want  string
+	}{
+		"null string":        {cty.NullVal(cty.String), "null"},
+		"empty string":       {cty.StringVal(""), "empty"},
+		"unknown string":     {cty.UnknownVal(cty.String), "unknown"},
+		"zero number":        {cty.NumberIntVal(0), "set"},
+		"false":              {cty.False, "set"},
+		"empty list":         {cty.ListValEmpty(cty.String), "empty"},
+		"empty map":          {cty.MapValEmpty(cty.String), "empty"},
+		"unknown list":       {cty.UnknownVal(cty.List(cty.String)), "unknown"},
+		"list with unknowns": {cty.ListVal([]cty.Value{cty.StringVal("a"), cty.UnknownVal(cty.String)}), "partially_unknown"},
+		"list":               {cty.ListVal([]cty.Value{cty.StringVal("a")}), "set"},
+	}
+	for name, c := range cases {
+		raw := cty.ObjectVal(map[string]cty.Value{"attr": c.value})
+		if got := describeRawAttribute(raw, "attr"); got != c.want {
+			t.Errorf("%s: expected %s, got %s", name, c.want, got)
+		}
+	}
+
+	// A data source has no raw plan at all, and an object can be unknown as
+	// a whole.
+	ty := cty.Object(map[string]cty.Type{"attr": cty.String})
+	for _, raw := range []cty.Value{cty.NullVal(ty), cty.UnknownVal(ty)} {
+		if got, want := describeRawAttribute(raw, "attr"), map[bool]string{true: "unknown", false: "null"}[!raw.IsKnown()]; got != want {
+			t.Errorf("%#v: expected %s, got %s", raw, want, got)
+		}
+	}
+}
+
+// testInspection returns the inspection of each attribute, from the
+// plan_inspection or apply_inspection of a mock_config_inspector.
+func testInspection(state cty.Value, attr string) map[string]map[string]cty.Value {
+	inspection := make(map[string]map[string]cty.Value)
+	for _, v := range state.GetAttr(attr).AsValueSlice() {
+		inspection[v.GetAttr("attribute").AsString()] = v.AsValueMap()
+	}
+	return inspection
+}
+
+func TestResourceConfigInspector(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	// Unknown values only show up at plan time.
+	planned := p.plan("mock_config_inspector", cty.NilVal, map[string]any{
+		"string_value": cty.UnknownVal(cty.String),
+		"list_value":   cty.ListVal([]cty.Value{cty.StringVal("a"), cty.UnknownVal(cty.String)}),
+		"map_value":    map[string]string{},
+		"int_value":    0,
+	})
+	plan := testInspection(planned, "plan_inspection")
+	for attr, want := range map[string]string{
+		"string_value": "unknown",
+		"list_value":   "partially_unknown",
+		"map_value":    "empty",
+		"int_value":    "set",
+		"bool_value":   "null",
+		"block":        "empty",
+	} {
+		if got := plan[attr]["raw_config"]; !got.RawEquals(cty.StringVal(want)) {
+			t.Errorf("expected the raw config of %s to be %s at plan time, got %#v", attr, want, got)
+		}
+	}
+	if planned.GetAttr("apply_inspection").IsKnown() {
+		t.Errorf("expected apply_inspection to be unknown at plan time")
+	}
+
+	// d.Get can't tell null from a zero value, and d.GetOk can't tell a zero
+	// value from null, but the raw config (and, for primitives, GetOkExists)
+	// can.
+	state := p.apply("mock_config_inspector", cty.NilVal, map[string]any{
+		"string_value": "",
+		"int_value":    0,
+	})
+	apply := testInspection(state, "apply_inspection")
+	for attr, want := range map[string]struct {
+		rawConfig   string
+		get         string
+		getOk       bool
+		getOkExists bool
+	}{
+		"string_value": {"empty", `""`, false, false},
+		"int_value":    {"set", "0", false, true},
+		"bool_value":   {"null", "false", false, false},
+		"list_value":   {"null", "[]interface {}{}", false, false},
+	} {
+		got := apply[attr]
+		if !got["raw_config"].RawEquals(cty.StringVal(want.rawConfig)) ||
+			!got["get"].RawEquals(cty.StringVal(want.get)) ||
+			!got["get_ok"].RawEquals(cty.BoolVal(want.getOk)) ||
+			!got["get_ok_exists"].RawEquals(cty.BoolVal(want.getOkExists)) {
+			t.Errorf("expected %s to be inspected as %+v, got %#v", attr, want, got)
+		}
+	}
+	p.planEmpty("mock_config_inspector", state, map[string]any{"string_value": "", "int_value": 0})
+}
@@ -0,0 +1,112 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Creating a mock_example takes more than one call to the backend: the object
+// is created with its own attributes, then its foo entries are attached, and
+// then its baz settings are applied. Any of the later steps can fail, which
+// leaves a partially created object behind.
+//
+// There are two ways of dealing with that, chosen by the resource's
+// 'create_failure_strategy' attribute:
+//
+//   - taint (the default): keep the ID in state and return an error.
+//     Terraform saves the object to state but marks it as 'tainted', and the
+//     next plan replaces it (the partial object is deleted and created again
+//     from scratch).
+//   - rollback: delete the partial object (a 'compensating' action) and clear
+//     the ID before returning the error. Nothing is saved to state, and the
+//     next plan simply creates it.
+//
+// The 'fail_create_step' attribute makes one of the steps fail on purpose so
+// the two can be compared.
+const (
+	createStepAttachFoo = "attach_foo"
+	createStepApplyBaz  = "apply_baz"
+
+	createFailureTaint    = "taint"
+	createFailureRollback = "rollback"
+)
+
+// exampleStep is one of the backend calls that together make up creating or
+// updating an example. It's given the revision the object is at.
+type exampleStep struct {
+	name string
+	call func(ctx context.Context, revision int) (*operation, error)
+
+	// request describes the call, as recorded in 'backend_calls'.
+	request string
+}
+
+// runExampleStep makes the step's backend call and waits for it to complete,
+// returning the completed operation. The step fails on purpose if the
+// attribute named by failKey (e.g. 'fail_create_step') is set to its name.
+func runExampleStep(ctx context.Context, d *schema.ResourceData, c *Client, step exampleStep, revision int, failKey string, timeout time.Duration) (*operation, error) {
+	log.Printf(">>> step %q (revision %d)", step.name, revision)
+
+	if d.Get(failKey).(string) == step.name {
+		return nil, fmt.Errorf("simulated failure (%s = %q)", failKey, step.name)
+	}
+
+	op, err := step.call(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitForOperation(ctx, c, op, timeout); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// createStepFailed deals with the partially created object left behind by a
+// failed step, according to the 'create_failure_strategy'.
+func createStepFailed(ctx context.Context, d *schema.ResourceData, m any, step string, stepErr error) diag.Diagnostics {
+	c := m.(*Client)
+	summary := fmt.Sprintf("Failed to create example: the %s step failed", step)
+
+	if d.Get("create_failure_strategy").(string) == createFailureRollback {
+		log.Printf(">>> rolling back the partially created example %q", d.Id())
+
+		op, err := c.backend.deleteExample(ctx, d.Id())
+		if err == nil {
+			err = waitForOperation(ctx, c, op, d.Timeout(schema.TimeoutCreate))
+		}
+		if err == nil || errors.Is(err, errNotFound) {
+			d.SetId("")
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  summary,
+				Detail: stepErr.Error() + "\n\nThe partially created object was deleted again " +
+					"(create_failure_strategy = \"rollback\") and nothing was saved to state.",
+			}}
+		}
+
+		// If we can't delete it either, the worst thing we could do is forget
+		// about it, so we fall back to leaving it tainted.
+		stepErr = fmt.Errorf("%w (rolling back also failed: %s)", stepErr, err)
+	}
+
+	// The ID is still set, so whatever we put into state is saved (and
+	// tainted). READ makes sure that's what actually exists rather than what
+	// was planned.
+	//
+	// NOTE: Except for sets (i.e. baz): the SDK can't rebuild a set reliably
+	// after an apply, so it always returns the planned one, and only the
+	// next refresh shows what really made it to the backend.
+	diags := resourceRead(ctx, d, m)
+	return append(diags, diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  summary,
+		Detail: stepErr.Error() + "\n\nThe partially created object was saved to state and marked as " +
+			"tainted, so the next apply will replace it.",
+	})
+}
@@ -0,0 +1,75 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestCreateStepFailed(t *testing.T) {
+	foo := []map[string]any{{"bar": []map[string]any{{"number": 1}}}}
+
+	t.Run("taint", func(t *testing.T) {
+		testProviderEnv(t)
+		p := newTestGRPCProvider(t, nil)
+
+		state, diags := p.tryApply("mock_example", cty.NilVal, testExampleConfig(map[string]any{
+			"foo":              foo,
+			"fail_create_step": createStepApplyBaz,
+		}))
+		testExpectError(t, diags, "^Failed to create example: the apply_baz step failed$", `^simulated failure \(fail_create_step = "apply_baz"\)(?s).*marked as tainted`)
+
+		// The ID stays in state, which terraform then marks as tainted, and
+		// the state is what the backend has: foo was attached, baz wasn't.
+		if state.IsNull() || state.GetAttr("id").IsNull() {
+			t.Fatalf("expected the partially created example to be kept in state, got %#v", state)
+		}
+		stored, err := p.client().backend.getExample(context.Background(), state.GetAttr("id").AsString())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.Foo) != 1 || len(stored.Baz) != 0 {
+			t.Errorf("expected the backend to have foo and no baz, got foo %v and baz %v", stored.Foo, stored.Baz)
+		}
+		if got := state.GetAttr("foo"); got.LengthInt() != 1 {
+			t.Errorf("expected foo in state, got %#v", got)
+		}
+		want := cty.ListVal([]cty.Value{cty.StringVal("POST example"), cty.StringVal("PUT foo")})
+		if got := state.GetAttr("backend_calls"); !got.RawEquals(want) {
+			t.Errorf("expected backend_calls to be %#v, got %#v", want, got)
+		}
+
+		// The SDK returns the planned baz after any apply, as it does for
+		// every set, so it takes a refresh to see there isn't one.
+		if got := p.read("mock_example", state).GetAttr("baz"); got.LengthInt() != 0 {
+			t.Errorf("expected no baz after a refresh, got %#v", got)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		testProviderEnv(t)
+		p := newTestGRPCProvider(t, nil)
+
+		state, diags := p.tryApply("mock_example", cty.NilVal, testExampleConfig(map[string]any{
+			"foo":                     foo,
+			"fail_create_step":        createStepApplyBaz,
+			"create_failure_strategy": createFailureRollback,
+		}))
+		testExpectError(t, diags, "^Failed to create example: the apply_baz step failed$", "deleted again")
+
+		// Nothing is left, in state or in the backend.
+		if !state.IsNull() {
+			t.Errorf("expected nothing in state, got %#v", state)
+		}
+		err := p.client().backend.(*backend).view(func(s *backendState) error {
+			if len(s.Examples) != 0 {
+				t.Errorf("expected the example to be deleted, got %d examples", len(s.Examples))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
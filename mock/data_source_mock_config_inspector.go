@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceConfigInspector is the data source flavour of
+// mock_config_inspector (see config_inspector.go).
+//
+// NOTE: A data source never sees an unknown value. If its configuration
+// references something that isn't known yet, terraform defers reading the
+// data source until apply, by which point everything is known. There's also
+// no plan, so GetRawPlan is always null.
+func dataSourceConfigInspector() *schema.Resource {
+	s := configInspectorInputs()
+	s["inspection"] = configInspectionSchema("How each input attribute looked when the data source was read.")
+
+	return &schema.Resource{
+		Description: "Records whether each input attribute was null, empty or set, as seen by the different ResourceData accessors.",
+		ReadContext: dataSourceConfigInspectorRead,
+		Schema:      s,
+	}
+}
+
+func dataSourceConfigInspectorRead(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+	// Data sources still need an ID, otherwise terraform considers them gone.
+	d.SetId("config_inspector")
+
+	if err := d.Set("inspection", inspectConfig(d)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// alreadyExistsDiagnostic turns the backend's 'already exists' error into a
+// diagnostic that tells the user how to bring the existing object under
+// terraform's management, rather than just telling them they can't create it.
+//
+// NOTE:
+// The provider is never told the address of the resource it's working on
+// (e.g. mock_example.testing), which is why the import command has a
+// placeholder the user has to fill in, and the detail says so.
+func alreadyExistsDiagnostic(resourceType string, err error) diag.Diagnostics {
+	var exists *alreadyExistsError
+	if !errors.As(err, &exists) {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("%s already exists", resourceType),
+		Detail: fmt.Sprintf(
+			"%s.\n\n"+
+				"If the existing object should be managed by this configuration, import it into "+
+				"the terraform state instead of creating a new one:\n\n"+
+				"%s",
+			err, importInstructions(resourceType, exists.id),
+		),
+	}}
+}
+
+// nameTakenDiagnostic is alreadyExistsDiagnostic for an update that renames
+// an object to a name another object already has. Importing the other object
+// isn't enough on its own here, as this one is already in the state.
+func nameTakenDiagnostic(resourceType string, err error) diag.Diagnostics {
+	var exists *alreadyExistsError
+	if !errors.As(err, &exists) {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("%s name is already in use", resourceType),
+		Detail: fmt.Sprintf(
+			"%s, so this %s can't be renamed to %q.\n\n"+
+				"Choose a different name. Or, if the existing object is the one this configuration "+
+				"should manage, remove this one from the terraform state (`terraform state rm`) and "+
+				"import the existing one in its place:\n\n"+
+				"%s",
+			err, resourceType, exists.name, importInstructions(resourceType, exists.id),
+		),
+	}}
+}
+
+// importInstructions returns the commands to import the object id into a
+// resourceType resource.
+func importInstructions(resourceType, id string) string {
+	return fmt.Sprintf(
+		"  terraform import %s.<NAME> %s\n\n"+
+			"or, with terraform 1.5 and later, add an import block:\n\n"+
+			"  import {\n    to = %s.<NAME>\n    id = %q\n  }\n\n"+
+			"where <NAME> is the name of the %q resource block in your configuration (with its "+
+			"index, e.g. %s.<NAME>[0], if it uses count or for_each). Terraform doesn't tell "+
+			"providers the address of the resource they're working on, so it can't be filled in here.",
+		resourceType, id, resourceType, id, resourceType, resourceType,
+	)
+}
+
+// withNamePrefixHint adds a hint about name_prefix to an 'already exists'
+// diagnostic, for resources that support it.
+func withNamePrefixHint(diags diag.Diagnostics) diag.Diagnostics {
+	for i := range diags {
+		diags[i].Detail += "\n\nIf this is a replacement using create_before_destroy, the new and old " +
+			"objects can't share a name. Use `name_prefix` instead of `name` so the backend " +
+			"generates a unique name for each."
+	}
+	return diags
+}
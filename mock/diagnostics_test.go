@@ -0,0 +1,115 @@
+package mock
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+// NOTE: Terraform wraps long diagnostics to fit the terminal, so the
+// patterns below allow any whitespace between words.
+
+func TestAlreadyExistsDiagnostic(t *testing.T) {
+	const existing = `
+resource "mock_secret" "existing" {
+  name  = "collision"
+  value = "one"
+}
+`
+	unitTest(t, resource.TestCase{
+		Steps: []resource.TestStep{
+			{
+				Config: existing,
+			},
+			{
+				// The secret that's already in the backend can be imported...
+				Config:            existing,
+				ResourceName:      "mock_secret.existing",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// ...which is what the error for a second secret with the
+				// same name tells the user to do.
+				Config: existing + `
+resource "mock_secret" "duplicate" {
+  name  = "collision"
+  value = "two"
+
+  depends_on = [mock_secret.existing]
+}
+`,
+				ExpectError: regexp.MustCompile(`mock_secret already exists(.|\n)*terraform\s+import\s+mock_secret\.<NAME>`),
+			},
+		},
+	})
+}
+
+func TestNameTakenDiagnostic(t *testing.T) {
+	config := func(name string) string {
+		return `
+resource "mock_parent" "a" {
+  name = "a"
+}
+
+resource "mock_parent" "b" {
+  name = "` + name + `"
+}
+`
+	}
+	unitTest(t, resource.TestCase{
+		Steps: []resource.TestStep{
+			{
+				Config: config("b"),
+			},
+			{
+				Config:      config("a"),
+				ExpectError: regexp.MustCompile(`mock_parent name is already in use(.|\n)*can't\s+be\s+renamed\s+to\s+"a"`),
+			},
+		},
+	})
+}
+
+func TestAlreadyExistsDiagnostic_everyResource(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+	parent := p.apply("mock_parent", cty.NilVal, map[string]any{"name": "parent"})
+	parentID := parent.GetAttr("id").AsString()
+
+	for typ, config := range map[string]map[string]any{
+		"mock_counter": {"name": "collision"},
+		"mock_secret":  {"name": "collision", "value": "secret"},
+		"mock_parent":  {"name": "parent"},
+		"mock_child":   {"name": "collision", "parent_id": parentID},
+		"mock_example": testExampleConfig(map[string]any{"name": "collision"}),
+	} {
+		t.Run(typ, func(t *testing.T) {
+			existing := parent
+			if typ != "mock_parent" {
+				existing = p.apply(typ, cty.NilVal, config)
+			}
+			id := existing.GetAttr("id").AsString()
+
+			_, diags := p.tryApply(typ, cty.NilVal, config)
+			testExpectError(t, diags, "^"+typ+" already exists$", `already exists \(id: `+id+`\)(?s).*`+regexp.QuoteMeta("terraform import "+typ+".<NAME> "+id))
+		})
+	}
+}
+
+func TestNameTakenDiagnostic_renames(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	a := p.apply("mock_parent", cty.NilVal, map[string]any{"name": "a"})
+	b := p.apply("mock_parent", cty.NilVal, map[string]any{"name": "b"})
+	_, diags := p.tryApply("mock_parent", b, map[string]any{"name": "a"})
+	testExpectError(t, diags, "^mock_parent name is already in use$", `can't be renamed to "a"(?s).*terraform state rm.*`+regexp.QuoteMeta("terraform import mock_parent.<NAME> "+a.GetAttr("id").AsString()))
+
+	parentID := a.GetAttr("id").AsString()
+	x := p.apply("mock_child", cty.NilVal, map[string]any{"name": "x", "parent_id": parentID})
+	y := p.apply("mock_child", cty.NilVal, map[string]any{"name": "y", "parent_id": parentID})
+	_, diags = p.tryApply("mock_child", y, map[string]any{"name": "x", "parent_id": parentID})
+	testExpectError(t, diags, "^mock_child name is already in use$", `can't be renamed to "x"(?s).*`+regexp.QuoteMeta("terraform import mock_child.<NAME> "+x.GetAttr("id").AsString()))
+}
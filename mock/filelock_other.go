@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows)
+
+package mock
+
+// lockFile doesn't lock anything on this platform, so only one provider
+// process at a time should use a file that's locked with it (e.g. the backend
+// file or a cassette).
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package mock
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// NOTE: The syscall package doesn't have LockFileEx and UnlockFileEx, and
+// golang.org/x/sys/windows (which does) is a lot to vendor for two functions,
+// so they're called directly, the way the go command locks its own files.
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockfileExclusiveLock is LOCKFILE_EXCLUSIVE_LOCK.
+const lockfileExclusiveLock = 0x2
+
+// lockFile takes an exclusive lock on path, creating it if need be, and
+// waits for as long as another process holds it. The lock is released by
+// calling unlock, or when the process exits, however it exits.
+func lockFile(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	h := f.Fd()
+	overlapped := new(syscall.Overlapped)
+	if ok, _, err := procLockFileEx.Call(h, lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(overlapped))); ok == 0 {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		_, _, _ = procUnlockFileEx.Call(h, 0, 1, 0, uintptr(unsafe.Pointer(overlapped)))
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,95 @@
+package mock
+
+import "strconv"
+
+// Terraform hands UPDATE the old and new versions of the whole foo list, and
+// the simplest thing to do is send the backend the whole new list (see
+// setExampleFoo). That's fine until the API is rate limited, or the entries
+// are large, or someone else is changing other entries at the same time.
+//
+// diffExampleFoo works out the individual changes instead, so that they can
+// be sent as a single JSON Patch style request (see patchExampleFoo) which
+// only touches the entries that actually changed.
+
+// fooIdentity returns what identifies the foo entry at position i: its key if
+// it has one, and its position otherwise.
+//
+// NOTE: Without a key, inserting an entry at the start of the list looks like
+// every entry changed, because they've all moved position. That's the same
+// problem terraform itself has with lists (see "Lists vs Sets" in the README)
+// and the same reason resources like aws_security_group identify their rules
+// by their contents rather than their position.
+func fooIdentity(i int, f exampleFoo) string {
+	if f.Key != "" {
+		return "key:" + f.Key
+	}
+	return "index:" + strconv.Itoa(i)
+}
+
+// diffExampleFoo returns the patch ops that turn old into new. It returns
+// false if that can't be done, i.e. entries have been reordered, and the
+// whole list should be replaced instead.
+func diffExampleFoo(old, new []exampleFoo) ([]fooPatchOp, bool) {
+	oldByID := make(map[string]exampleFoo, len(old))
+	for i, f := range old {
+		oldByID[fooIdentity(i, f)] = f
+	}
+	newIDs := make(map[string]bool, len(new))
+	for i, f := range new {
+		newIDs[fooIdentity(i, f)] = true
+	}
+
+	var ops []fooPatchOp
+
+	// Removals go first, from the end of the list so that removing one entry
+	// doesn't change the index of the next one to be removed.
+	for i := len(old) - 1; i >= 0; i-- {
+		if !newIDs[fooIdentity(i, old[i])] {
+			ops = append(ops, fooPatchOp{Op: "remove", Index: i})
+		}
+	}
+
+	// working is the identities of the entries the backend will have, in
+	// order, as the ops are applied.
+	var working []string
+	for i, f := range old {
+		if id := fooIdentity(i, f); newIDs[id] {
+			working = append(working, id)
+		}
+	}
+
+	for j, f := range new {
+		f := f
+		id := fooIdentity(j, f)
+
+		prev, existed := oldByID[id]
+		if !existed {
+			ops = append(ops, fooPatchOp{Op: "add", Index: j, Value: &f})
+			working = append(working[:j], append([]string{id}, working[j:]...)...)
+			continue
+		}
+		if j >= len(working) || working[j] != id {
+			// The entry has moved, which a patch can't express.
+			return nil, false
+		}
+		if !fooEqual(prev, f) {
+			ops = append(ops, fooPatchOp{Op: "replace", Index: j, Value: &f})
+		}
+	}
+
+	return ops, true
+}
+
+// fooEqual reports whether two foo entries have the same configuration. The
+// bar versions are ignored as they're assigned by the backend.
+func fooEqual(a, b exampleFoo) bool {
+	if a.Key != b.Key || len(a.Bar) != len(b.Bar) {
+		return false
+	}
+	for i := range a.Bar {
+		if !sameNumber(a.Bar[i].Number, b.Bar[i].Number) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package mock
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/go-cty/cty/msgpack"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// "Provider produced inconsistent result after apply" is what terraform
+// reports when the state a provider returns from an apply doesn't match what
+// it said it would be in the plan. It's always a provider bug, and these are
+// the classic ways of causing it, which mock_example can be told to reproduce
+// with its 'inconsistency_mode' attribute.
+const (
+	// inconsistencyPlannedValue has the backend store a different value to
+	// the one it was sent (like an API that normalises its input), so READ
+	// returns something other than what was planned.
+	inconsistencyPlannedValue = "planned_value"
+
+	// inconsistencyReadDrift has READ report a value for a non-computed
+	// attribute that isn't what the backend holds.
+	inconsistencyReadDrift = "read_drift"
+
+	// inconsistencyDropBar has READ leave out the last foo's nested bar block.
+	inconsistencyDropBar = "drop_bar"
+)
+
+// inconsistencyModes are the valid values of 'inconsistency_mode'.
+var inconsistencyModes = []string{
+	inconsistencyPlannedValue,
+	inconsistencyReadDrift,
+	inconsistencyDropBar,
+}
+
+// injectPlannedValueInconsistency changes the example that's about to be sent
+// to the backend when the inconsistencyPlannedValue mode is enabled.
+func injectPlannedValueInconsistency(d *schema.ResourceData, e *example) {
+	if d.Get("inconsistency_mode").(string) != inconsistencyPlannedValue {
+		return
+	}
+	log.Printf(">>> inconsistency_mode: modifying not_computed_required before sending it to the backend")
+	e.NotComputedRequired += " (normalised)"
+}
+
+// injectReadInconsistency changes what READ is about to put into state when
+// one of the READ based modes is enabled.
+func injectReadInconsistency(d *schema.ResourceData, e *example) {
+	switch d.Get("inconsistency_mode").(string) {
+	case inconsistencyReadDrift:
+		log.Printf(">>> inconsistency_mode: adding an element to some_list")
+		e.SomeList = append(e.SomeList, "added-by-read")
+	case inconsistencyDropBar:
+		if len(e.Foo) > 0 {
+			log.Printf(">>> inconsistency_mode: dropping bar from the last foo")
+			e.Foo[len(e.Foo)-1].Bar = nil
+		}
+	}
+}
+
+// NOTE: Terraform only reports an inconsistent result as an error for
+// providers built with the plugin framework. The SDK we're using can't
+// describe its values precisely enough to pass terraform's checks (e.g. it
+// can't tell a null list from an empty one), so every response it sends has
+// UnsafeToUseLegacyTypeSystem set, and terraform downgrades the error to a
+// warning that only appears in the logs (TF_LOG=WARN):
+//
+//	[WARN] Provider "registry.terraform.io/integralist/mock" produced an
+//	unexpected new value for mock_example.x, but we are tolerating it because
+//	it is using the legacy plugin SDK.
+//
+// To see the real error, the 'strict_consistency' provider argument has the
+// ProviderServer returned by GRPCProviderServer clear that flag.
+//
+// It only does so for resources that have 'inconsistency_mode' set. The flag
+// is there for good reason, and clearing it for everything risks failing
+// normal applies too (wherever the SDK stores "" or an empty list for a value
+// that terraform planned as null).
+
+// strictProviderServer wraps the SDK's ProviderServer so that terraform
+// checks the provider's results as strictly as it would for a provider built
+// with the plugin framework.
+type strictProviderServer struct {
+	tfprotov5.ProviderServer
+	provider *schema.Provider
+}
+
+// GRPCProviderServer returns the ProviderServer that main.go serves.
+func GRPCProviderServer() tfprotov5.ProviderServer {
+	p := Provider()
+	return &strictProviderServer{
+		ProviderServer: schema.NewGRPCProviderServer(p),
+		provider:       p,
+	}
+}
+
+// strict reports whether the result of applying req should be checked
+// strictly. The provider's meta is only available once terraform has
+// configured it.
+func (s *strictProviderServer) strict(req *tfprotov5.ApplyResourceChangeRequest) bool {
+	c, ok := s.provider.Meta().(*Client)
+	if !ok || !c.strictConsistency {
+		return false
+	}
+
+	r, ok := s.provider.ResourcesMap[req.TypeName]
+	if !ok || req.PlannedState == nil {
+		return false
+	}
+	planned, err := msgpack.Unmarshal(req.PlannedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+	if err != nil || planned.IsNull() || !planned.Type().HasAttribute("inconsistency_mode") {
+		return false
+	}
+	mode := planned.GetAttr("inconsistency_mode")
+	return mode.IsKnown() && !mode.IsNull() && mode.AsString() != ""
+}
+
+func (s *strictProviderServer) ApplyResourceChange(ctx context.Context, req *tfprotov5.ApplyResourceChangeRequest) (*tfprotov5.ApplyResourceChangeResponse, error) {
+	strict := s.strict(req)
+
+	resp, err := s.ProviderServer.ApplyResourceChange(ctx, req)
+	if resp != nil && strict {
+		log.Printf(">>> strict_consistency: clearing UnsafeToUseLegacyTypeSystem for %s", req.TypeName)
+		resp.UnsafeToUseLegacyTypeSystem = false
+	}
+	return resp, err
+}
@@ -0,0 +1,90 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+)
+
+// testApplyResponse plans and applies the creation of a mock_example, and
+// returns the planned state and the response to the apply as it is, flags
+// and all.
+func testApplyResponse(p *testGRPCProvider, config map[string]any) (cty.Value, cty.Value, *tfprotov5.ApplyResourceChangeResponse) {
+	p.t.Helper()
+	c := p.config("mock_example", config)
+	planned, plan, diags := p.tryPlan("mock_example", cty.NilVal, c)
+	p.check("plan", diags)
+	resp, err := p.server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+		TypeName:       "mock_example",
+		PriorState:     p.encode(cty.NullVal(c.Type())),
+		PlannedState:   p.encode(planned),
+		Config:         p.encode(c),
+		PlannedPrivate: plan.PlannedPrivate,
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	p.check("apply", resp.Diagnostics)
+	return planned, p.decode(resp.NewState, c.Type()), resp
+}
+
+func TestStrictProviderServer(t *testing.T) {
+	testProviderEnv(t)
+
+	// Nothing is strict before the provider has been configured.
+	server := GRPCProviderServer().(*strictProviderServer)
+	if server.strict(&tfprotov5.ApplyResourceChangeRequest{TypeName: "mock_example"}) {
+		t.Error("expected an unconfigured provider not to be strict")
+	}
+
+	// UnsafeToUseLegacyTypeSystem is only cleared when strict_consistency
+	// and inconsistency_mode are both set.
+	for _, c := range []struct {
+		strict bool
+		mode   string
+	}{
+		{false, ""},
+		{false, inconsistencyPlannedValue},
+		{true, ""},
+		{true, inconsistencyPlannedValue},
+	} {
+		p := newTestGRPCProvider(t, map[string]any{"strict_consistency": c.strict})
+		config := testExampleConfig(nil)
+		if c.mode != "" {
+			config["inconsistency_mode"] = c.mode
+		}
+		_, _, resp := testApplyResponse(p, config)
+		if want := !(c.strict && c.mode != ""); resp.UnsafeToUseLegacyTypeSystem != want {
+			t.Errorf("strict_consistency = %t, inconsistency_mode = %q: expected UnsafeToUseLegacyTypeSystem to be %t", c.strict, c.mode, want)
+		}
+	}
+}
+
+func TestInconsistencyModes(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, map[string]any{"strict_consistency": true})
+
+	foo := []map[string]any{{"bar": []map[string]any{{"number": 1}}}}
+	for mode, check := range map[string]func(planned, applied cty.Value) bool{
+		// Each mode makes the applied state differ from the planned one in
+		// its own way.
+		inconsistencyPlannedValue: func(planned, applied cty.Value) bool {
+			return applied.GetAttr("not_computed_required").RawEquals(cty.StringVal("required (normalised)"))
+		},
+		inconsistencyReadDrift: func(planned, applied cty.Value) bool {
+			list := applied.GetAttr("some_list")
+			return list.LengthInt() == 1 && list.Index(cty.NumberIntVal(0)).RawEquals(cty.StringVal("added-by-read"))
+		},
+		inconsistencyDropBar: func(planned, applied cty.Value) bool {
+			return planned.GetAttr("foo").Index(cty.NumberIntVal(0)).GetAttr("bar").LengthInt() == 1 &&
+				applied.GetAttr("foo").Index(cty.NumberIntVal(0)).GetAttr("bar").LengthInt() == 0
+		},
+	} {
+		planned, applied, _ := testApplyResponse(p, testExampleConfig(map[string]any{"inconsistency_mode": mode, "foo": foo}))
+		if !check(planned, applied) {
+			t.Errorf("%s: unexpected result after apply:\n%#v", mode, applied)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package mock
+
+import (
+	"log"
+	"sync"
+)
+
+// mutexKV is a simple key/value store for arbitrary mutexes. It can be used to
+// serialise changes across arbitrary collaborators that share knowledge of the
+// keys they must serialise on.
+//
+// Terraform walks the resource graph in parallel (10 operations at a time by
+// default) and so two CRUD functions can easily be running at the same time
+// within the same provider process. When those functions perform a
+// read-modify-write against the same remote object, one of them will silently
+// overwrite the other's change unless we serialise them.
+//
+// NOTE:
+// This is the same approach the AWS and Google providers take (they each have
+// their own 'mutexkv' package). The lock only protects against concurrency
+// within a single provider process, it does nothing to protect against two
+// separate terraform runs (that's what ETags/revisions are for).
+type mutexKV struct {
+	lock  sync.Mutex
+	store map[string]*sync.Mutex
+}
+
+// newMutexKV returns a properly initialised mutexKV.
+func newMutexKV() *mutexKV {
+	return &mutexKV{
+		store: make(map[string]*sync.Mutex),
+	}
+}
+
+// Lock the mutex for the given key. Caller is responsible for calling Unlock
+// for the same key.
+func (m *mutexKV) Lock(key string) {
+	log.Printf(">>> locking %q", key)
+	m.get(key).Lock()
+	log.Printf(">>> locked %q", key)
+}
+
+// Unlock the mutex for the given key. Caller must have called Lock for the
+// same key first.
+func (m *mutexKV) Unlock(key string) {
+	log.Printf(">>> unlocking %q", key)
+	m.get(key).Unlock()
+	log.Printf(">>> unlocked %q", key)
+}
+
+// get returns a mutex for the given key, creating it if it doesn't exist yet.
+func (m *mutexKV) get(key string) *sync.Mutex {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	mutex, ok := m.store[key]
+	if !ok {
+		mutex = &sync.Mutex{}
+		m.store[key] = mutex
+	}
+	return mutex
+}
@@ -1,6 +1,20 @@
 package mock
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
 	// Documentation:
 	// https://pkg.go.dev/github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema
 	//
@@ -21,6 +35,154 @@ func Provider() *schema.Provider {
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("MOCK_FOO", nil),
 			},
+			"backend_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: defaultBackendFile,
+				Description: "Path to the JSON file the mock backend persists its objects to (or `MOCK_BACKEND_FILE`). An empty string keeps everything in memory, which only lasts as long as the provider process does. Defaults to a file for the current working directory in the `terraform-provider-mock` directory of the user's cache directory (e.g. `~/.cache` on Linux), so every terraform configuration has a backend of its own.",
+			},
+			// These settings can also come from environment variables or a
+			// profile in the shared config file, which is why they don't
+			// have a DefaultFunc (see provider_settings.go).
+			"profile": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("profile", "The profile in `shared_config_file` to read settings from."),
+			},
+			"shared_config_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("shared_config_file", "Path to the shared config file."),
+			},
+			"api_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("api_url", "URL of a mock API server started with `terraform-provider-mock serve-api`. When set, the provider talks to the backend over HTTP, and `backend_file` and `async_operations` are ignored (they're up to the server)."),
+			},
+			"token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: settingDescription("token", "Bearer token sent to the mock API server, if it was started with `-tokens-file`."),
+			},
+			"client_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("client_id", "OAuth2 client ID used to get short-lived access tokens from the mock API server, instead of a `token`."),
+			},
+			"client_secret": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: settingDescription("client_secret", "OAuth2 client secret that goes with `client_id`."),
+			},
+			"ca_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("ca_cert_file", "Path to a PEM encoded CA certificate to trust when `api_url` is an https:// URL, e.g. the `ca.pem` generated by `serve-api -tls`."),
+			},
+			"client_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("client_cert_file", "Path to a PEM encoded client certificate, for a mock API server started with `-require-client-cert`."),
+			},
+			"client_key_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: settingDescription("client_key_file", "Path to the PEM encoded private key of `client_cert_file`."),
+			},
+			"insecure_skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: settingDescription("insecure_skip_verify", "Don't verify the mock API server's certificate. Only ever meant for testing."),
+			},
+			"max_attempts": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  settingDescription("max_attempts", "How many times a request to the mock API server is attempted before giving up, when it's rate limited, fails with a 5xx error or gets no response."),
+			},
+			"cassette_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MOCK_CASSETTE_FILE", nil),
+				Description: "Path to a cassette file to record the provider's requests to the mock API server in, or to replay them from (see `cassette_mode`).",
+			},
+			"cassette_mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("MOCK_CASSETTE_MODE", nil),
+				ValidateFunc: validation.StringInSlice([]string{cassetteModeRecord, cassetteModeReplay}, false),
+				Description:  "`record` to send requests to the mock API server and record them in `cassette_file`, or `replay` to answer them from `cassette_file` without a server (in which case `api_url` isn't needed).",
+			},
+			"disable_locking": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MOCK_DISABLE_LOCKING", false),
+				Description: "Disable the provider-level mutexes so that the race conditions they prevent can be reproduced.",
+			},
+			"strict_consistency": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MOCK_STRICT_CONSISTENCY", false),
+				Description: "Have terraform report an inconsistent result after apply as an error rather than a logged warning (see `inconsistency_mode` on mock_example).",
+			},
+			"conflict_policy": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      conflictPolicyError,
+				ValidateFunc: validation.StringInSlice([]string{conflictPolicyError, conflictPolicyRefreshAndRetry}, false),
+				Description:  "What to do when an update is rejected because the object was changed since it was last read: `error` or `refresh_and_retry`.",
+			},
+			"default_tags": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Tags applied to every resource that supports tags.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"tags": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The tags to apply. A resource's own `tags` take precedence.",
+						},
+					},
+				},
+			},
+			// When this block is present the backend processes changes to
+			// mock_example asynchronously, like a real cloud API would.
+			"async_operations": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Make the backend return long-running operations from create/update/delete, which the provider then has to poll.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"pending_duration": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "2s",
+							ValidateDiagFunc: validateDuration,
+							Description:      "How long an operation stays `PENDING`.",
+						},
+						"running_duration": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "5s",
+							ValidateDiagFunc: validateDuration,
+							Description:      "How long an operation stays `RUNNING` before it's `DONE` (or `FAILED`).",
+						},
+						"failure_rate": {
+							Type:         schema.TypeFloat,
+							Optional:     true,
+							Default:      0,
+							ValidateFunc: validation.FloatBetween(0, 1),
+							Description:  "The probability (between 0 and 1) of an operation ending up `FAILED`.",
+						},
+					},
+				},
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			// Naming format...
@@ -33,7 +195,14 @@ func Provider() *schema.Provider {
 			// provider would add to their terraform HCL file.
 			// e.g. resource "mock_example" "my_own_name_for_this" {...}
 			//
-			"mock_example": resourceExample(),
+			"mock_example":          resourceExample(),
+			"mock_counter":          resourceCounter(),
+			"mock_counter_member":   resourceCounterMember(),
+			"mock_secret":           resourceSecret(),
+			"mock_parent":           resourceParent(),
+			"mock_child":            resourceChild(),
+			"mock_all_types":        resourceAllTypes(),
+			"mock_config_inspector": resourceConfigInspector(),
 		},
 		// DataSource is a subset of Resource.
 		DataSourcesMap: map[string]*schema.Resource{
@@ -47,7 +216,8 @@ func Provider() *schema.Provider {
 			// provider would add to their terraform HCL file.
 			// e.g. data_source "mock_example" "my_own_name_for_this" {...}
 			//
-			"mock_example": dataSourceExample(),
+			"mock_example":          dataSourceExample(),
+			"mock_config_inspector": dataSourceConfigInspector(),
 		},
 
 		// To configure the provider (i.e. create an API client)
@@ -58,5 +228,331 @@ func Provider() *schema.Provider {
 		// Documentation:
 		// https://pkg.go.dev/github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema#ConfigureFunc
 		// https://pkg.go.dev/github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema#ConfigureContextFunc
+		ConfigureContextFunc: providerConfigure,
 	}
 }
+
+// providerConfigure builds the *Client that's passed to every CRUD function.
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (any, diag.Diagnostics) {
+	b := newBackend(d.Get("backend_file").(string))
+
+	if v, ok := d.GetOk("async_operations"); ok {
+		async := v.([]any)[0].(map[string]any)
+
+		// The durations were validated by validateDuration so we can ignore
+		// the errors here.
+		pending, _ := time.ParseDuration(async["pending_duration"].(string))
+		running, _ := time.ParseDuration(async["running_duration"].(string))
+
+		b.async = asyncOptions{
+			Enabled:         true,
+			PendingDuration: pending,
+			RunningDuration: running,
+			FailureRate:     async["failure_rate"].(float64),
+		}
+	}
+
+	settings, diags := resolveProviderSettings(ctx, d)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	apiURL := settings.String("api_url")
+	cassette, cassetteDiags := configureCassette(d, apiURL)
+	if diags = append(diags, settings.annotate(cassetteDiags)...); diags.HasError() {
+		return nil, diags
+	}
+	if apiURL == "" && cassette != nil && cassette.mode == cassetteModeReplay {
+		apiURL = cassetteReplayURL
+	}
+
+	var backend backendAPI = b
+	if apiURL != "" {
+		opts := apiClientOptions{
+			MaxAttempts:  settings.Int("max_attempts"),
+			Token:        settings.String("token"),
+			ClientID:     settings.String("client_id"),
+			ClientSecret: settings.String("client_secret"),
+			Cassette:     cassette,
+		}
+		if diags = append(diags, validateAPICredentials(opts, settings)...); diags.HasError() {
+			return nil, diags
+		}
+
+		tlsConfig, tlsDiags := clientTLSConfig(apiTLSOptions{
+			CACertFile:         settings.String("ca_cert_file"),
+			ClientCertFile:     settings.String("client_cert_file"),
+			ClientKeyFile:      settings.String("client_key_file"),
+			InsecureSkipVerify: settings.Bool("insecure_skip_verify"),
+		})
+		if diags = append(diags, settings.annotate(tlsDiags)...); diags.HasError() {
+			return nil, diags
+		}
+		if tlsConfig != nil && !strings.HasPrefix(apiURL, "https://") {
+			diags = append(diags, settings.annotate(diag.Diagnostics{{
+				Severity:      diag.Warning,
+				Summary:       "TLS settings are ignored for an http:// api_url",
+				Detail:        fmt.Sprintf("`ca_cert_file`, `client_cert_file`, `client_key_file` and `insecure_skip_verify` only apply to https:// URLs, and `api_url` is %s.", apiURL),
+				AttributePath: cty.GetAttrPath("api_url"),
+			}})...)
+		}
+		opts.TLSConfig = tlsConfig
+
+		client := newAPIClient(apiURL, opts)
+		backend = client
+
+		diags = append(diags, settings.annotate(checkAPIToken(ctx, client, apiURL))...)
+		if diags.HasError() {
+			return nil, diags
+		}
+
+		if _, ok := d.GetOk("async_operations"); ok {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "async_operations is ignored when api_url is set",
+				Detail:   "The mock API server decides whether operations are asynchronous, not the provider.",
+			})
+		}
+	} else {
+		for _, k := range []string{"token", "client_id"} {
+			if settings.String(k) != "" {
+				diags = append(diags, settings.annotate(diag.Diagnostics{{
+					Severity:      diag.Warning,
+					Summary:       fmt.Sprintf("%s is ignored when api_url isn't set", k),
+					Detail:        "Only the mock API server needs credentials. The local backend doesn't.",
+					AttributePath: cty.GetAttrPath(k),
+				}})...)
+			}
+		}
+	}
+
+	var defaultTags map[string]string
+	if v, ok := d.GetOk("default_tags"); ok && v.([]any)[0] != nil {
+		defaultTags = expandTags(v.([]any)[0].(map[string]any)["tags"])
+	}
+
+	return &Client{
+		backend:         backend,
+		mutexes:         newMutexKV(),
+		lockingDisabled: d.Get("disable_locking").(bool),
+		conflictPolicy:  d.Get("conflict_policy").(string),
+		defaultTags:     defaultTags,
+
+		strictConsistency: d.Get("strict_consistency").(bool),
+	}, diags
+}
+
+// validateAPICredentials checks that the provider was given one kind of
+// credentials for the mock API server, or none at all.
+//
+// NOTE: This can't be done with ConflictsWith and RequiredWith in the schema,
+// as they only look at the configuration, and these arguments can also come
+// from environment variables and profiles. Which is also why the errors say
+// where each of them was set: when the token comes from MOCK_API_TOKEN and
+// the client_id from a profile, neither of them is in the configuration
+// terraform shows.
+func validateAPICredentials(opts apiClientOptions, settings *resolvedSettings) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if opts.Token != "" && opts.ClientID != "" {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Conflicting mock API credentials",
+			Detail: fmt.Sprintf("Set either `token` or `client_id` and `client_secret`, not both.\n\n`token` was set by %s, and `client_id` by %s.",
+				settings.sources["token"], settings.sources["client_id"]),
+		})
+	}
+	if (opts.ClientID == "") != (opts.ClientSecret == "") {
+		missing, set := "client_secret", "client_id"
+		if opts.ClientID == "" {
+			missing, set = set, missing
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Incomplete mock API client credentials",
+			Detail: fmt.Sprintf("`client_id` and `client_secret` have to be set together, and only `%s` was (by %s).",
+				set, settings.sources[set]),
+			AttributePath: cty.GetAttrPath(missing),
+		})
+	}
+	return diags
+}
+
+// checkAPIToken asks the mock API server who the client's token belongs to.
+//
+// NOTE: Without this, a missing or wrong token would only show up when the
+// first resource is read, as an error that seems to be about that resource.
+// Checking it here means the error says what's actually wrong, and where to
+// fix it, before anything else happens.
+func checkAPIToken(ctx context.Context, client *apiClient, apiURL string) diag.Diagnostics {
+	who, err := client.whoami(ctx)
+
+	var apiErr *apiError
+	var oauthErr *oauthError
+	switch {
+	case err == nil:
+	case errors.As(err, &oauthErr) && oauthErr.Code == "invalid_client":
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid mock API client credentials",
+			Detail:        fmt.Sprintf("The mock API server at %s wouldn't issue an access token: %s.\n\nCheck `client_id` and `client_secret` against the server's tokens file.", apiURL, oauthErr.Description),
+			AttributePath: cty.GetAttrPath("client_secret"),
+		}}
+	case errors.As(err, &apiErr) && apiErr.Code == apiErrorUnauthorized:
+		detail := fmt.Sprintf("The mock API server at %s rejected the provider's token: %s.\n\nCheck `token` against the server's tokens file.", apiURL, apiErr.Message)
+		if client.token == "" && client.oauth == nil {
+			detail = fmt.Sprintf("The mock API server at %s needs a token.\n\nSet `token` (in the provider configuration, with MOCK_API_TOKEN, or in a profile) to one of the tokens in the server's tokens file.", apiURL)
+		}
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid mock API token",
+			Detail:        detail,
+			AttributePath: cty.GetAttrPath("token"),
+		}}
+	case errors.As(err, &apiErr) && apiErr.Code == apiErrorForbidden:
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Mock API token not permitted",
+			Detail:        fmt.Sprintf("The mock API server at %s accepted the provider's token but doesn't allow it to be used: %s.", apiURL, apiErr.Message),
+			AttributePath: cty.GetAttrPath("token"),
+		}}
+	case tlsErrorHint(err) != "":
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Unable to connect to the mock API server securely",
+			Detail:        fmt.Sprintf("%s\n\n%s", err, tlsErrorHint(err)),
+			AttributePath: cty.GetAttrPath("api_url"),
+		}}
+	default:
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Unable to check the mock API token",
+			Detail:        fmt.Sprintf("Checking the provider's token with GET %s/v1/whoami failed: %s", strings.TrimSuffix(apiURL, "/"), err),
+			AttributePath: cty.GetAttrPath("api_url"),
+		}}
+	}
+
+	log.Printf(">>> mock API token %q has scope %q", who.Name, who.Scope)
+	if who.Scope == tokenScopeReadOnly {
+		return diag.Diagnostics{{
+			Severity:      diag.Warning,
+			Summary:       "The mock API token is read-only",
+			Detail:        fmt.Sprintf("Token %q can read objects but not create, change or delete them, so applying any change will fail with a permission denied error.", who.Name),
+			AttributePath: cty.GetAttrPath("token"),
+		}}
+	}
+	return nil
+}
+
+// validateDuration checks the value can be parsed by time.ParseDuration.
+func validateDuration(v any, path cty.Path) diag.Diagnostics {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid duration",
+			Detail:        fmt.Sprintf("%q is not a valid duration (e.g. \"30s\" or \"1m30s\"): %s", v, err),
+			AttributePath: path,
+		}}
+	}
+	return nil
+}
+
+// defaultBackendFile is the DefaultFunc of 'backend_file'. Like
+// EnvDefaultFunc, it's only called when backend_file isn't in the
+// configuration at all, so 'backend_file = ""' still means "keep everything
+// in memory" (and so does an empty MOCK_BACKEND_FILE). GetRawConfig would be
+// the usual way to tell the two apart, but the SDK doesn't pass the raw
+// config to ConfigureContextFunc.
+func defaultBackendFile() (any, error) {
+	if path, ok := os.LookupEnv("MOCK_BACKEND_FILE"); ok {
+		return path, nil
+	}
+
+	path, err := defaultBackendPath()
+	if err != nil {
+		log.Printf(">>> keeping the mock backend in memory, as the default backend_file can't be used: %s", err)
+		return "", nil
+	}
+	log.Printf(">>> using the default backend_file %s", path)
+	return path, nil
+}
+
+// defaultBackendPath returns the backend file used when none is given: a
+// file in the user's cache directory that belongs to the current working
+// directory, so that every terraform configuration has a backend of its own
+// (and so does a `serve-api` run in the same directory as terraform).
+//
+// NOTE: The default used to be a file in the current directory, which left a
+// stray file in every directory terraform was ever run in. Then it was a
+// single file in the cache directory, which terraform configurations applied
+// side by side had to share, so names taken in one collided with the other.
+func defaultBackendPath() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "terraform-provider-mock")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	// The name of the directory is only there to make the file easy to find:
+	// the hash is what tells two directories with the same name apart.
+	sum := sha256.Sum256([]byte(wd))
+	return filepath.Join(dir, fmt.Sprintf("backend-%s-%x.json", filepath.Base(wd), sum[:6])), nil
+}
+
+// configureCassette returns the cassette the API client records its requests
+// to, or replays them from, if 'cassette_file' is set (see api_cassette.go).
+func configureCassette(d *schema.ResourceData, apiURL string) (*cassette, diag.Diagnostics) {
+	path, mode := d.Get("cassette_file").(string), d.Get("cassette_mode").(string)
+
+	var problem diag.Diagnostic
+	switch {
+	case path == "" && mode == "":
+		return nil, nil
+	case path == "":
+		problem = diag.Diagnostic{
+			Summary:       "cassette_mode needs a cassette_file",
+			Detail:        fmt.Sprintf("`cassette_mode` is %q, but there's no `cassette_file` to %s.", mode, mode),
+			AttributePath: cty.GetAttrPath("cassette_file"),
+		}
+	case mode == "":
+		problem = diag.Diagnostic{
+			Summary:       "cassette_file needs a cassette_mode",
+			Detail:        fmt.Sprintf("Set `cassette_mode` to %q to record requests to %s, or to %q to replay them from it.", cassetteModeRecord, path, cassetteModeReplay),
+			AttributePath: cty.GetAttrPath("cassette_mode"),
+		}
+	case mode != cassetteModeRecord && mode != cassetteModeReplay:
+		// The schema checks the argument, but not MOCK_CASSETTE_MODE.
+		problem = diag.Diagnostic{
+			Summary:       "Invalid cassette_mode",
+			Detail:        fmt.Sprintf("`cassette_mode` should be %q or %q, not %q.", cassetteModeRecord, cassetteModeReplay, mode),
+			AttributePath: cty.GetAttrPath("cassette_mode"),
+		}
+	case mode == cassetteModeRecord && apiURL == "":
+		problem = diag.Diagnostic{
+			Summary:       "Recording a cassette needs a mock API server",
+			Detail:        "Only requests to the mock API server are recorded, and without `api_url` the provider uses the local backend, which it doesn't make any requests to.",
+			AttributePath: cty.GetAttrPath("api_url"),
+		}
+	default:
+		c := newCassette(path, mode)
+		if err := c.prepare(); err != nil {
+			problem = diag.Diagnostic{
+				Summary:       fmt.Sprintf("Unable to %s the cassette", mode),
+				Detail:        err.Error(),
+				AttributePath: cty.GetAttrPath("cassette_file"),
+			}
+			break
+		}
+		log.Printf(">>> cassette_mode is %q: %s", mode, path)
+		return c, nil
+	}
+
+	problem.Severity = diag.Error
+	return nil, diag.Diagnostics{problem}
+}
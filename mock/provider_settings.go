@@ -0,0 +1,438 @@
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Most real providers can be configured in more than one way: with the
+// provider's arguments, with environment variables, and with a named profile
+// in a file shared by every tool that talks to the same API (think of
+// ~/.aws/credentials). The settings for connecting to the mock API server
+// work the same way. For each of them, the first of these that's set wins:
+//
+//  1. The argument in the provider block.
+//  2. Its environment variable, e.g. MOCK_API_URL.
+//  3. The profile (the 'profile' argument, or MOCK_PROFILE, or "default") in
+//     the shared config file (the 'shared_config_file' argument, or
+//     MOCK_SHARED_CONFIG_FILE, or ~/.mock/credentials).
+//  4. The default.
+//
+// The shared config file looks like this:
+//
+//	[default]
+//	api_url = http://127.0.0.1:8080
+//
+//	[ci]
+//	api_url       = https://localhost:8443
+//	client_id     = deployer
+//	client_secret = ...
+//	ca_cert_file  = /etc/mock/ca.pem
+//
+// NOTE: The SDK can do (1), (2) and (4) by itself, with a DefaultFunc, but
+// then there's no telling which of them a value came from, and so no way of
+// fitting (3) in between them. That's why the settings below don't have a
+// DefaultFunc in the schema, and are resolved by resolveProviderSettings
+// instead.
+//
+// Knowing where each value came from also makes for better errors: when a
+// setting is wrong, the diagnostic says where it was set (see annotate), which
+// is the first thing anyone wants to know once there's more than one place it
+// could have been.
+
+// providerSetting is a provider argument that can also be set with an
+// environment variable or in a profile.
+type providerSetting struct {
+	key    string
+	envVar string
+
+	// def is the default value, and its type is the type of the setting:
+	// string, bool or int.
+	def any
+
+	// sensitive settings are never logged.
+	sensitive bool
+}
+
+// profileSettings are the settings that can be set in a profile.
+var profileSettings = []providerSetting{
+	{key: "api_url", envVar: "MOCK_API_URL", def: ""},
+	{key: "token", envVar: "MOCK_API_TOKEN", def: "", sensitive: true},
+	{key: "client_id", envVar: "MOCK_CLIENT_ID", def: ""},
+	{key: "client_secret", envVar: "MOCK_CLIENT_SECRET", def: "", sensitive: true},
+	{key: "ca_cert_file", envVar: "MOCK_CA_CERT_FILE", def: ""},
+	{key: "client_cert_file", envVar: "MOCK_CLIENT_CERT_FILE", def: ""},
+	{key: "client_key_file", envVar: "MOCK_CLIENT_KEY_FILE", def: ""},
+	{key: "insecure_skip_verify", envVar: "MOCK_INSECURE_SKIP_VERIFY", def: false},
+	{key: "max_attempts", envVar: "MOCK_MAX_ATTEMPTS", def: defaultMaxAttempts},
+}
+
+// The settings that choose the profile, which can't come from a profile
+// themselves.
+var (
+	profileSetting          = providerSetting{key: "profile", envVar: "MOCK_PROFILE", def: defaultProfile}
+	sharedConfigFileSetting = providerSetting{key: "shared_config_file", envVar: "MOCK_SHARED_CONFIG_FILE", def: defaultSharedConfigFile}
+)
+
+const (
+	defaultProfile          = "default"
+	defaultSharedConfigFile = "~/.mock/credentials"
+)
+
+// The kinds of place a setting can come from, from most to least important.
+const (
+	sourceConfig  = "config"
+	sourceEnv     = "env"
+	sourceProfile = "profile"
+	sourceDefault = "default"
+)
+
+// settingSource is where the value of a setting came from.
+type settingSource struct {
+	kind string
+
+	// detail is the environment variable, or the profile and file, that set
+	// it.
+	detail string
+}
+
+func (s settingSource) String() string {
+	switch s.kind {
+	case sourceConfig:
+		return "the provider configuration"
+	case sourceEnv:
+		return fmt.Sprintf("the %s environment variable", s.detail)
+	case sourceProfile:
+		return s.detail
+	}
+	return "its default value"
+}
+
+// resolvedSettings are the values of the settings, and where they came from.
+type resolvedSettings struct {
+	values  map[string]any
+	sources map[string]settingSource
+}
+
+func (s *resolvedSettings) String(key string) string { return s.values[key].(string) }
+func (s *resolvedSettings) Bool(key string) bool     { return s.values[key].(bool) }
+func (s *resolvedSettings) Int(key string) int       { return s.values[key].(int) }
+
+// annotate adds where the setting was set to any diagnostic about a setting
+// that was set outside of the provider configuration (which terraform already
+// points at).
+func (s *resolvedSettings) annotate(diags diag.Diagnostics) diag.Diagnostics {
+	for i := range diags {
+		path := diags[i].AttributePath
+		if len(path) != 1 {
+			continue
+		}
+		step, ok := path[0].(cty.GetAttrStep)
+		if !ok {
+			continue
+		}
+		source, ok := s.sources[step.Name]
+		if !ok || source.kind == sourceConfig || source.kind == sourceDefault {
+			continue
+		}
+		diags[i].Detail += fmt.Sprintf("\n\n`%s` was set by %s.", step.Name, source)
+	}
+	return diags
+}
+
+// resolveProviderSettings works out the value of each of the profileSettings
+// (see the top of this file).
+func resolveProviderSettings(ctx context.Context, d *schema.ResourceData) (*resolvedSettings, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	s := &resolvedSettings{
+		values:  make(map[string]any),
+		sources: make(map[string]settingSource),
+	}
+
+	for _, setting := range []providerSetting{profileSetting, sharedConfigFileSetting} {
+		if err := s.resolve(d, setting, nil, ""); err != nil {
+			diags = append(diags, s.annotate(diag.Diagnostics{settingError(setting, err)})...)
+		}
+	}
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	profileName := s.String("profile")
+	path, err := expandHome(s.String("shared_config_file"))
+	if err != nil {
+		return nil, s.annotate(append(diags, settingError(sharedConfigFileSetting, err)))
+	}
+
+	// Not having a shared config file is fine, unless a file or profile was
+	// asked for.
+	profile, err := loadProfile(path, profileName)
+	var noSuchProfile *noSuchProfileError
+	optional := s.sources["profile"].kind == sourceDefault && s.sources["shared_config_file"].kind == sourceDefault
+	switch {
+	case errors.Is(err, os.ErrNotExist) && optional:
+		profile = nil
+	case errors.As(err, &noSuchProfile) && s.sources["profile"].kind == sourceDefault:
+		profile = nil
+	case err != nil:
+		setting := sharedConfigFileSetting
+		if noSuchProfile != nil {
+			setting = profileSetting
+		}
+		return nil, s.annotate(append(diags, settingError(setting, err)))
+	}
+
+	known := make(map[string]bool, len(profileSettings))
+	for _, setting := range profileSettings {
+		known[setting.key] = true
+		if err := s.resolve(d, setting, profile, fmt.Sprintf("profile %q in %s", profileName, path)); err != nil {
+			diags = append(diags, s.annotate(diag.Diagnostics{settingError(setting, err)})...)
+		}
+	}
+	for _, key := range sortedKeys(profile) {
+		if !known[key] {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unknown setting in shared config file",
+				Detail:   fmt.Sprintf("Profile %q in %s sets %q, which isn't a setting the provider knows about, so it's ignored.", profileName, path, key),
+			})
+		}
+	}
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	// NOTE: With several places a setting can come from, "why is it using
+	// that?" is the first question anyone asks, so every setting is logged
+	// along with where it came from (TF_LOG=INFO or more to see it).
+	for _, setting := range append([]providerSetting{profileSetting, sharedConfigFileSetting}, profileSettings...) {
+		source := s.sources[setting.key]
+		fields := map[string]any{
+			"setting": setting.key,
+			"value":   s.values[setting.key],
+			"source":  source.kind,
+		}
+		if source.detail != "" {
+			fields["source_detail"] = source.detail
+		}
+		if setting.sensitive && s.values[setting.key] != "" {
+			fields["value"] = redacted
+		}
+		tflog.Info(ctx, fmt.Sprintf("Provider setting %s was set by %s", setting.key, source), fields)
+	}
+	return s, diags
+}
+
+// settingDescription adds where else a setting can be set, and which of
+// those places wins, to the description of its argument.
+func settingDescription(key, description string) string {
+	for _, setting := range append([]providerSetting{profileSetting, sharedConfigFileSetting}, profileSettings...) {
+		if setting.key != key {
+			continue
+		}
+		if _, ok := setting.def.(string); ok && setting.def != "" {
+			return fmt.Sprintf("%s If it isn't set, `%s` is used, and then `%s`.", description, setting.envVar, setting.def)
+		}
+		return fmt.Sprintf("%s If it isn't set, `%s` is used, and then the `profile`.", description, setting.envVar)
+	}
+	return description
+}
+
+// resolve works out the value of one setting. An error is about the value
+// that was found, which annotate says where it came from.
+func (s *resolvedSettings) resolve(d *schema.ResourceData, setting providerSetting, profile map[string]string, profileDetail string) error {
+	if v, ok := configValue(d, setting); ok {
+		s.values[setting.key] = v
+		s.sources[setting.key] = settingSource{kind: sourceConfig}
+		return nil
+	}
+
+	if raw := os.Getenv(setting.envVar); raw != "" {
+		s.sources[setting.key] = settingSource{kind: sourceEnv, detail: setting.envVar}
+		v, err := parseSetting(setting, raw)
+		if err != nil {
+			return err
+		}
+		s.values[setting.key] = v
+		return nil
+	}
+
+	if raw, ok := profile[setting.key]; ok {
+		s.sources[setting.key] = settingSource{kind: sourceProfile, detail: profileDetail}
+		v, err := parseSetting(setting, raw)
+		if err != nil {
+			return err
+		}
+		s.values[setting.key] = v
+		return nil
+	}
+
+	s.values[setting.key] = setting.def
+	s.sources[setting.key] = settingSource{kind: sourceDefault}
+	return nil
+}
+
+// configValue returns the value of a setting in the provider configuration,
+// if it's set there.
+//
+// NOTE: Without a DefaultFunc, an argument that isn't set is its zero value,
+// so an empty string counts as not set. A bool is different, as 'false' can
+// be there to override a profile, so we need GetOkExists (which is
+// deprecated because it can't tell 'false' from unset in nested blocks, but
+// works for top-level arguments like these).
+func configValue(d *schema.ResourceData, setting providerSetting) (any, bool) {
+	switch setting.def.(type) {
+	case bool:
+		v, ok := d.GetOkExists(setting.key) //nolint:staticcheck
+		return v, ok
+	case int:
+		v := d.Get(setting.key).(int)
+		return v, v != 0
+	default:
+		v := d.Get(setting.key).(string)
+		return v, v != ""
+	}
+}
+
+// parseSetting parses the value of a setting from an environment variable or
+// a profile.
+func parseSetting(setting providerSetting, raw string) (any, error) {
+	switch setting.def.(type) {
+	case bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s should be true or false, not %q", setting.key, raw)
+		}
+		return v, nil
+	case int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s should be a whole number, not %q", setting.key, raw)
+		}
+		// The schema only validates values in the configuration.
+		if setting.key == "max_attempts" && v < 1 {
+			return nil, fmt.Errorf("%s should be at least 1, not %d", setting.key, v)
+		}
+		return v, nil
+	default:
+		return raw, nil
+	}
+}
+
+func settingError(setting providerSetting, err error) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       fmt.Sprintf("Invalid %s", setting.key),
+		Detail:        err.Error(),
+		AttributePath: cty.GetAttrPath(setting.key),
+	}
+}
+
+// noSuchProfileError is returned by loadProfile when the file doesn't have
+// the profile.
+type noSuchProfileError struct {
+	path     string
+	name     string
+	profiles []string
+}
+
+func (e *noSuchProfileError) Error() string {
+	return fmt.Sprintf("%s doesn't have a [%s] profile (it has: %s)", e.path, e.name, strings.Join(e.profiles, ", "))
+}
+
+// loadProfile returns the settings in a profile of a shared config file.
+func loadProfile(path, name string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared config file: %w", err)
+	}
+	profiles, err := parseSharedConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse shared config file %s: %w", path, err)
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, &noSuchProfileError{path: path, name: name, profiles: names}
+	}
+	return profile, nil
+}
+
+// parseSharedConfig parses an INI style file into its profiles. Lines
+// starting with '#' or ';' are comments, and values can be quoted.
+func parseSharedConfig(data []byte) (map[string]map[string]string, error) {
+	profiles := make(map[string]map[string]string)
+	var current map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: a profile needs a name", n)
+			}
+			if _, ok := profiles[name]; ok {
+				return nil, fmt.Errorf("line %d: profile %q is defined twice", n, name)
+			}
+			current = make(map[string]string)
+			profiles[name] = current
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key = value' or '[profile]'", n)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: %q isn't in a profile", n, strings.TrimSpace(key))
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		current[strings.TrimSpace(key)] = value
+	}
+	return profiles, scanner.Err()
+}
+
+// expandHome replaces a leading "~" in path with the user's home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory for %s: %w", path, err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
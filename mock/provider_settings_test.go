@@ -0,0 +1,78 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResolveProviderSettingsLogsSources(t *testing.T) {
+	for _, setting := range profileSettings {
+		t.Setenv(setting.envVar, "")
+	}
+	t.Setenv("MOCK_PROFILE", "")
+	t.Setenv("MOCK_BACKEND_FILE", "")
+
+	sharedConfigFile := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(sharedConfigFile, []byte("[ci]\nclient_id = deployer\nclient_secret = from-profile\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MOCK_SHARED_CONFIG_FILE", sharedConfigFile)
+	t.Setenv("MOCK_API_URL", "http://127.0.0.1:8080")
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]any{
+		"profile":      "ci",
+		"max_attempts": 2,
+	})
+
+	var output bytes.Buffer
+	settings, diags := resolveProviderSettings(tflogtest.RootLogger(context.Background(), &output), d)
+	if diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	if got := settings.String("client_id"); got != "deployer" {
+		t.Errorf("expected client_id to come from the profile, got %q", got)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := make(map[string]map[string]any)
+	for _, entry := range entries {
+		if key, ok := entry["setting"].(string); ok {
+			logged[key] = entry
+		}
+	}
+
+	want := map[string]struct {
+		source string
+		value  any
+	}{
+		"profile":       {sourceConfig, "ci"},
+		"max_attempts":  {sourceConfig, float64(2)},
+		"api_url":       {sourceEnv, "http://127.0.0.1:8080"},
+		"client_id":     {sourceProfile, "deployer"},
+		"client_secret": {sourceProfile, redacted},
+		"token":         {sourceDefault, ""},
+	}
+	for key, w := range want {
+		entry, ok := logged[key]
+		if !ok {
+			t.Errorf("%s wasn't logged", key)
+			continue
+		}
+		if entry["@level"] != "info" || entry["source"] != w.source || entry["value"] != w.value {
+			t.Errorf("expected %s to be logged at info with source %s and value %v, got %v", key, w.source, w.value, entry)
+		}
+	}
+	if detail := logged["api_url"]["source_detail"]; detail != "MOCK_API_URL" {
+		t.Errorf("expected api_url's source_detail to be MOCK_API_URL, got %v", detail)
+	}
+}
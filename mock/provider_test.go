@@ -0,0 +1,522 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/go-cty/cty/gocty"
+	"github.com/hashicorp/go-cty/cty/msgpack"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The tests that call unitTest run real terraform commands (init, plan,
+// apply, import, destroy) against the provider, in-process, so they need a
+// terraform CLI. The SDK looks for one in TF_ACC_TERRAFORM_PATH, or
+// downloads TF_ACC_TERRAFORM_VERSION, or falls back to the PATH. Without any
+// of them the tests are skipped rather than failed, so that `go test ./...`
+// still works on a machine without terraform.
+//
+// NOTE: These are "unit" tests in the SDK's sense (resource.UnitTest rather
+// than resource.Test), which only means they don't need TF_ACC=1. As the
+// backend is local there's nothing that costs money or needs credentials, so
+// there's no reason to hide them behind TF_ACC like a real provider would.
+
+// protoV5ProviderFactories serves the same gRPC server main.go does, so that
+// the tests go through strictProviderServer too.
+var protoV5ProviderFactories = map[string]func() (tfprotov5.ProviderServer, error){
+	"mock": func() (tfprotov5.ProviderServer, error) {
+		return GRPCProviderServer(), nil
+	},
+}
+
+// unitTest runs c with resource.UnitTest, against a backend of its own (see
+// testProviderEnv).
+func unitTest(t *testing.T, c resource.TestCase) {
+	t.Helper()
+
+	if os.Getenv("TF_ACC_TERRAFORM_PATH") == "" && os.Getenv("TF_ACC_TERRAFORM_VERSION") == "" {
+		if _, err := exec.LookPath("terraform"); err != nil {
+			t.Skip("no terraform CLI: set TF_ACC_TERRAFORM_PATH, or TF_ACC_TERRAFORM_VERSION to download one")
+		}
+	}
+
+	testProviderEnv(t)
+	c.ProtoV5ProviderFactories = protoV5ProviderFactories
+	resource.UnitTest(t, c)
+}
+
+// testProviderEnv gives the providers a test configures a backend of their
+// own.
+//
+// Terraform starts the provider again for every command, so the backend has
+// to be a file to last from one step to the next. The provider settings that
+// could send it to a mock API server instead are cleared, in case they're
+// set in the environment or the shared config file of whoever runs the test.
+func testProviderEnv(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("MOCK_BACKEND_FILE", filepath.Join(dir, "backend.json"))
+	for _, setting := range profileSettings {
+		t.Setenv(setting.envVar, "")
+	}
+	sharedConfigFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(sharedConfigFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MOCK_SHARED_CONFIG_FILE", sharedConfigFile)
+	t.Setenv("MOCK_PROFILE", "")
+	t.Setenv("MOCK_CASSETTE_FILE", "")
+	t.Setenv("MOCK_CASSETTE_MODE", "")
+}
+
+func TestProvider(t *testing.T) {
+	if err := Provider().InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultBackendPath(t *testing.T) {
+	// os.UserCacheDir uses XDG_CACHE_HOME on Linux and the BSDs.
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" || runtime.GOOS == "plan9" {
+		t.Skip("the cache directory can't be moved to a temporary one on " + runtime.GOOS)
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	paths := make(map[string]string)
+	root := t.TempDir()
+	for _, dir := range []string{"a/project", "b/project", "a/project"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(filepath.Join(root, dir)); err != nil {
+			t.Fatal(err)
+		}
+		path, err := defaultBackendPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filepath.Dir(path); got != filepath.Join(cache, "terraform-provider-mock") {
+			t.Errorf("expected %s to be in the cache directory", path)
+		}
+		if !strings.HasPrefix(filepath.Base(path), "backend-project-") {
+			t.Errorf("expected %s to be named after its directory", path)
+		}
+		if seen, ok := paths[dir]; ok && seen != path {
+			t.Errorf("expected %s to get the same backend file every time, got %s and %s", dir, seen, path)
+		}
+		paths[dir] = path
+	}
+	if paths["a/project"] == paths["b/project"] {
+		t.Errorf("expected directories with the same name to get backend files of their own, both got %s", paths["a/project"])
+	}
+}
+
+// testGRPCProvider drives the provider through its gRPC server, the way
+// terraform does, for tests that don't need terraform itself (and so run
+// wherever `go test` does).
+//
+// Configurations are written as Go values: strings, numbers, bools, slices
+// and maps, with a map for each nested block, or a cty.Value for anything
+// else (e.g. an unknown value). They're turned into what terraform would
+// send using the schema (see testConfigValue).
+//
+// NOTE: Only the parts of terraform that the provider can see are done here.
+// In particular the proposed new state of a plan is worked out much like
+// terraform does it (see testProposedNew), but elements of a set of blocks
+// aren't matched up with the prior state.
+type testGRPCProvider struct {
+	t        *testing.T
+	server   tfprotov5.ProviderServer
+	provider *schema.Provider
+}
+
+// newTestGRPCProvider returns a provider configured with config, failing the
+// test if it can't be configured.
+func newTestGRPCProvider(t *testing.T, config map[string]any) *testGRPCProvider {
+	t.Helper()
+	p, diags := configureTestGRPCProvider(t, config)
+	p.check("configure", diags)
+	return p
+}
+
+// configureTestGRPCProvider returns a provider configured with config, and
+// the diagnostics of configuring it.
+func configureTestGRPCProvider(t *testing.T, config map[string]any) (*testGRPCProvider, []*tfprotov5.Diagnostic) {
+	t.Helper()
+	server := GRPCProviderServer()
+	p := &testGRPCProvider{t: t, server: server, provider: server.(*strictProviderServer).provider}
+
+	ty := schema.InternalMap(p.provider.Schema).CoreConfigSchema().ImpliedType()
+	resp, err := server.ConfigureProvider(context.Background(), &tfprotov5.ConfigureProviderRequest{
+		Config: p.encode(testConfigValue(t, p.provider.Schema, ty, config)),
+	})
+	if err != nil {
+		t.Fatalf("configure: %s", err)
+	}
+	return p, resp.Diagnostics
+}
+
+// client is what the provider's CRUD functions are given as their meta, for
+// looking at the backend behind the provider's back.
+func (p *testGRPCProvider) client() *Client {
+	return p.provider.Meta().(*Client)
+}
+
+// config returns the configuration of a resource of type typ.
+func (p *testGRPCProvider) config(typ string, config map[string]any) cty.Value {
+	p.t.Helper()
+	r := p.provider.ResourcesMap[typ]
+	return testConfigValue(p.t, r.Schema, r.CoreConfigSchema().ImpliedType(), config)
+}
+
+// validate returns the diagnostics of validating the configuration of a
+// resource, which is where ConflictsWith and friends are checked.
+func (p *testGRPCProvider) validate(typ string, config map[string]any) []*tfprotov5.Diagnostic {
+	p.t.Helper()
+	resp, err := p.server.ValidateResourceTypeConfig(context.Background(), &tfprotov5.ValidateResourceTypeConfigRequest{
+		TypeName: typ,
+		Config:   p.encode(p.config(typ, config)),
+	})
+	if err != nil {
+		p.t.Fatalf("validate: %s", err)
+	}
+	return resp.Diagnostics
+}
+
+// plan plans config over prior (cty.NilVal for a new object), and returns
+// the planned state.
+func (p *testGRPCProvider) plan(typ string, prior cty.Value, config map[string]any) cty.Value {
+	p.t.Helper()
+	planned, _, diags := p.tryPlan(typ, prior, p.config(typ, config))
+	p.check("plan", diags)
+	return planned
+}
+
+// planEmpty checks that planning config over prior changes nothing.
+func (p *testGRPCProvider) planEmpty(typ string, prior cty.Value, config map[string]any) {
+	p.t.Helper()
+	if planned := p.plan(typ, prior, config); !planned.RawEquals(prior) {
+		p.t.Fatalf("expected an empty plan, got:\n%#v\nover:\n%#v", planned, prior)
+	}
+}
+
+func (p *testGRPCProvider) tryPlan(typ string, prior, config cty.Value) (cty.Value, *tfprotov5.PlanResourceChangeResponse, []*tfprotov5.Diagnostic) {
+	p.t.Helper()
+	if prior == cty.NilVal {
+		prior = cty.NullVal(config.Type())
+	}
+	resp, err := p.server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+		TypeName:         typ,
+		PriorState:       p.encode(prior),
+		ProposedNewState: p.encode(testProposedNew(p.provider.ResourcesMap[typ].Schema, prior, config)),
+		Config:           p.encode(config),
+	})
+	if err != nil {
+		p.t.Fatalf("plan: %s", err)
+	}
+	if resp.PlannedState == nil {
+		return cty.NilVal, resp, resp.Diagnostics
+	}
+	return p.decode(resp.PlannedState, config.Type()), resp, resp.Diagnostics
+}
+
+// apply plans and applies config over prior (cty.NilVal for a new object),
+// and returns the new state.
+func (p *testGRPCProvider) apply(typ string, prior cty.Value, config map[string]any) cty.Value {
+	p.t.Helper()
+	state, diags := p.tryApply(typ, prior, config)
+	p.check("apply", diags)
+	return state
+}
+
+// tryApply is apply for when it's expected to fail: it returns the new state
+// (which terraform would save, even after an error) and the diagnostics of
+// the plan or the apply, whichever failed.
+func (p *testGRPCProvider) tryApply(typ string, prior cty.Value, config map[string]any) (cty.Value, []*tfprotov5.Diagnostic) {
+	p.t.Helper()
+	c := p.config(typ, config)
+	if prior == cty.NilVal {
+		prior = cty.NullVal(c.Type())
+	}
+	return p.applyChange(typ, prior, c)
+}
+
+// destroy plans and applies the destruction of state.
+func (p *testGRPCProvider) destroy(typ string, state cty.Value) {
+	p.t.Helper()
+	_, diags := p.applyChange(typ, state, cty.NullVal(state.Type()))
+	p.check("destroy", diags)
+}
+
+// tryDestroy is destroy for when it's expected to fail.
+func (p *testGRPCProvider) tryDestroy(typ string, state cty.Value) []*tfprotov5.Diagnostic {
+	p.t.Helper()
+	_, diags := p.applyChange(typ, state, cty.NullVal(state.Type()))
+	return diags
+}
+
+func (p *testGRPCProvider) applyChange(typ string, prior, config cty.Value) (cty.Value, []*tfprotov5.Diagnostic) {
+	p.t.Helper()
+	planned, plan, diags := p.tryPlan(typ, prior, config)
+	if testHasError(diags) {
+		return prior, diags
+	}
+	resp, err := p.server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+		TypeName:       typ,
+		PriorState:     p.encode(prior),
+		PlannedState:   p.encode(planned),
+		Config:         p.encode(config),
+		PlannedPrivate: plan.PlannedPrivate,
+	})
+	if err != nil {
+		p.t.Fatalf("apply: %s", err)
+	}
+	return p.decode(resp.NewState, prior.Type()), resp.Diagnostics
+}
+
+// read refreshes state, returning a null value if the object is gone.
+func (p *testGRPCProvider) read(typ string, state cty.Value) cty.Value {
+	p.t.Helper()
+	resp, err := p.server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+		TypeName:     typ,
+		CurrentState: p.encode(state),
+	})
+	if err != nil {
+		p.t.Fatalf("read: %s", err)
+	}
+	p.check("read", resp.Diagnostics)
+	return p.decode(resp.NewState, state.Type())
+}
+
+// importState imports the object with the given ID, the way `terraform
+// import` does, and returns its state.
+func (p *testGRPCProvider) importState(typ, id string) cty.Value {
+	p.t.Helper()
+	resp, err := p.server.ImportResourceState(context.Background(), &tfprotov5.ImportResourceStateRequest{
+		TypeName: typ,
+		ID:       id,
+	})
+	if err != nil {
+		p.t.Fatalf("import: %s", err)
+	}
+	p.check("import", resp.Diagnostics)
+	if len(resp.ImportedResources) != 1 {
+		p.t.Fatalf("expected one object to be imported, got %d", len(resp.ImportedResources))
+	}
+	ty := p.provider.ResourcesMap[typ].CoreConfigSchema().ImpliedType()
+	return p.read(typ, p.decode(resp.ImportedResources[0].State, ty))
+}
+
+func (p *testGRPCProvider) check(what string, diags []*tfprotov5.Diagnostic) {
+	p.t.Helper()
+	for _, d := range diags {
+		if d.Severity == tfprotov5.DiagnosticSeverityError {
+			p.t.Fatalf("%s: %s: %s", what, d.Summary, d.Detail)
+		}
+	}
+}
+
+func (p *testGRPCProvider) encode(v cty.Value) *tfprotov5.DynamicValue {
+	p.t.Helper()
+	b, err := msgpack.Marshal(v, v.Type())
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return &tfprotov5.DynamicValue{MsgPack: b}
+}
+
+func (p *testGRPCProvider) decode(v *tfprotov5.DynamicValue, ty cty.Type) cty.Value {
+	p.t.Helper()
+	value, err := msgpack.Unmarshal(v.MsgPack, ty)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return value
+}
+
+func testHasError(diags []*tfprotov5.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == tfprotov5.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// testExpectError checks that diags has an error whose summary and detail
+// match the given patterns.
+func testExpectError(t *testing.T, diags []*tfprotov5.Diagnostic, summary, detail string) {
+	t.Helper()
+	for _, d := range diags {
+		if d.Severity == tfprotov5.DiagnosticSeverityError &&
+			regexp.MustCompile(summary).MatchString(d.Summary) &&
+			regexp.MustCompile(detail).MatchString(d.Detail) {
+			return
+		}
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, fmt.Sprintf("%s: %s", d.Summary, d.Detail))
+	}
+	t.Fatalf("expected an error matching %q / %q, got:\n%s", summary, detail, strings.Join(got, "\n"))
+}
+
+// testConfigValue turns a configuration written as Go values into the object
+// of type ty that terraform would send, with null for every attribute that
+// isn't set and no elements for every block that isn't.
+func testConfigValue(t *testing.T, s map[string]*schema.Schema, ty cty.Type, config map[string]any) cty.Value {
+	t.Helper()
+	values := make(map[string]cty.Value, len(ty.AttributeTypes()))
+	for name, at := range ty.AttributeTypes() {
+		raw, ok := config[name]
+		switch {
+		case ok:
+			values[name] = testValue(t, s[name], at, raw)
+		case testIsBlock(s[name]) && at.IsListType():
+			values[name] = cty.ListValEmpty(at.ElementType())
+		case testIsBlock(s[name]) && at.IsSetType():
+			values[name] = cty.SetValEmpty(at.ElementType())
+		default:
+			values[name] = cty.NullVal(at)
+		}
+	}
+	for name := range config {
+		if !ty.HasAttribute(name) {
+			t.Fatalf("the configuration has %q, which the schema doesn't", name)
+		}
+	}
+	return cty.ObjectVal(values)
+}
+
+func testValue(t *testing.T, s *schema.Schema, ty cty.Type, raw any) cty.Value {
+	t.Helper()
+	if v, ok := raw.(cty.Value); ok {
+		return v
+	}
+	if raw == nil {
+		return cty.NullVal(ty)
+	}
+
+	var elem *schema.Schema
+	if s != nil {
+		switch e := s.Elem.(type) {
+		case *schema.Schema:
+			elem = e
+		case *schema.Resource:
+			if ty.IsObjectType() {
+				return testConfigValue(t, e.Schema, ty, raw.(map[string]any))
+			}
+			elem = &schema.Schema{Elem: e}
+		}
+	}
+
+	// A block the SDK adds to the schema, such as 'timeouts'.
+	if ty.IsObjectType() {
+		return testConfigValue(t, nil, ty, raw.(map[string]any))
+	}
+
+	rv := reflect.ValueOf(raw)
+	switch {
+	case ty.IsListType() || ty.IsSetType():
+		var elems []cty.Value
+		for i := 0; i < rv.Len(); i++ {
+			elems = append(elems, testValue(t, elem, ty.ElementType(), rv.Index(i).Interface()))
+		}
+		switch {
+		case len(elems) == 0 && ty.IsListType():
+			return cty.ListValEmpty(ty.ElementType())
+		case len(elems) == 0:
+			return cty.SetValEmpty(ty.ElementType())
+		case ty.IsListType():
+			return cty.ListVal(elems)
+		}
+		return cty.SetVal(elems)
+	case ty.IsMapType():
+		elems := make(map[string]cty.Value, rv.Len())
+		for _, k := range rv.MapKeys() {
+			elems[k.String()] = testValue(t, elem, ty.ElementType(), rv.MapIndex(k).Interface())
+		}
+		if len(elems) == 0 {
+			return cty.MapValEmpty(ty.ElementType())
+		}
+		return cty.MapVal(elems)
+	}
+
+	v, err := gocty.ToCtyValue(raw, ty)
+	if err != nil {
+		t.Fatalf("%#v isn't a %s: %s", raw, ty.FriendlyName(), err)
+	}
+	return v
+}
+
+// testIsBlock reports whether s is a nested block rather than an attribute.
+// Like the SDK, it treats a computed list or set of objects that can't be
+// configured as an attribute.
+func testIsBlock(s *schema.Schema) bool {
+	if s == nil || s.ConfigMode == schema.SchemaConfigModeAttr || (s.Computed && !s.Optional) {
+		return false
+	}
+	_, ok := s.Elem.(*schema.Resource)
+	return ok && (s.Type == schema.TypeList || s.Type == schema.TypeSet)
+}
+
+// testProposedNew works out the proposed new state terraform gives a plan:
+// the configuration, with the prior value of every computed attribute that
+// isn't set in it. The elements of a list of blocks are matched with the
+// prior ones by their index, and a set of blocks is taken from the
+// configuration as it is.
+func testProposedNew(s map[string]*schema.Schema, prior, config cty.Value) cty.Value {
+	if config.IsNull() || !config.IsKnown() {
+		return config
+	}
+	values := make(map[string]cty.Value)
+	for name, cv := range config.AsValueMap() {
+		pv := cty.NullVal(cv.Type())
+		if !prior.IsNull() {
+			pv = prior.GetAttr(name)
+		}
+		sch, ok := s[name]
+		switch {
+		case !ok:
+			// 'id' and 'timeouts', which the SDK adds to the schema.
+			if cv.IsNull() && name == "id" {
+				cv = pv
+			}
+		case testIsBlock(sch) && sch.Type == schema.TypeList && cv.IsKnown() && !cv.IsNull():
+			var elems []cty.Value
+			for i, ev := range cv.AsValueSlice() {
+				pe := cty.NullVal(ev.Type())
+				if !pv.IsNull() && pv.IsKnown() && i < pv.LengthInt() {
+					pe = pv.Index(cty.NumberIntVal(int64(i)))
+				}
+				elems = append(elems, testProposedNew(sch.Elem.(*schema.Resource).Schema, pe, ev))
+			}
+			if len(elems) > 0 {
+				cv = cty.ListVal(elems)
+			}
+		case cv.IsNull() && sch.Computed:
+			cv = pv
+		}
+		values[name] = cv
+	}
+	return cty.ObjectVal(values)
+}
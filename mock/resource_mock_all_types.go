@@ -0,0 +1,471 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceAllTypes is a 'kitchen sink' resource that uses every attribute
+// type and schema behaviour the SDK offers, so there's a working example of
+// each one (and of how to get each one in and out of the backend).
+//
+// Reference:
+// https://developer.hashicorp.com/terraform/plugin/sdkv2/schemas/schema-types
+// https://developer.hashicorp.com/terraform/plugin/sdkv2/schemas/schema-behaviors
+func resourceAllTypes() *schema.Resource {
+	return &schema.Resource{
+		Description: "A resource with an attribute for every SDK attribute type and schema behaviour.",
+
+		CreateContext: resourceAllTypesCreate,
+		ReadContext:   resourceAllTypesRead,
+		UpdateContext: resourceAllTypesUpdate,
+		DeleteContext: resourceAllTypesDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			// Primitive types.
+			//
+			// NOTE:
+			// There's no way for d.Get to tell you whether a primitive was set to
+			// its zero value or not set at all (both come back as false, 0 or "").
+			"bool_value": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "A boolean.",
+			},
+			"float_value": {
+				Type:        schema.TypeFloat,
+				Optional:    true,
+				Description: "A floating point number.",
+			},
+			// 'Default' is used when the attribute isn't in the configuration.
+			// The plan shows the default value rather than '(known after apply)'.
+			"int_with_default": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     42,
+				Description: "An integer that defaults to 42.",
+			},
+			"string_value": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringLenBetween(1, 64),
+				Description:  "A string of between 1 and 64 characters.",
+			},
+
+			// Maps. The Elem decides the type of the values (the keys are always
+			// strings). Maps can only hold primitives, not blocks.
+			"string_map": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "A map of strings.",
+			},
+			"int_map": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "A map of integers.",
+			},
+			"float_map": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeFloat},
+				Description: "A map of floating point numbers.",
+			},
+			"bool_map": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeBool},
+				Description: "A map of booleans.",
+			},
+
+			// Sets. Order doesn't matter and duplicates are removed. An Elem of
+			// *schema.Schema gives an attribute (string_set = ["a", "b"]) while an
+			// Elem of *schema.Resource gives repeatable blocks (block_set { ... }).
+			"string_set": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "A set of strings.",
+			},
+			"int_set": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "A set of integers.",
+			},
+			"block_set": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "A set of key/value blocks.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"value": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			// Blocks can be nested as deep as you like. A TypeList with
+			// MaxItems 1 is the usual way to model a single nested object.
+			"nested": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "A single block containing deeper nested blocks.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"level_two": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"level_three": {
+										Type:     schema.TypeSet,
+										Optional: true,
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"value": {
+													Type:     schema.TypeString,
+													Required: true,
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			// Optional+Computed means the user can set the value, but if they
+			// don't then the backend decides it. Without Computed, a value
+			// chosen by the backend would show up as a diff on every plan.
+			"optional_computed": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Set by the backend when not configured.",
+			},
+
+			// ConflictsWith: at most one of the attributes can be set.
+			"conflicts_a": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"conflicts_b"},
+				Description:   "Can't be set at the same time as `conflicts_b`.",
+			},
+			"conflicts_b": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"conflicts_a"},
+				Description:   "Can't be set at the same time as `conflicts_a`.",
+			},
+
+			// ExactlyOneOf: one (and only one) of the attributes must be set.
+			// Every attribute in the group lists the whole group, including
+			// itself.
+			"exactly_one_a": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"exactly_one_a", "exactly_one_b"},
+				Description:  "Exactly one of `exactly_one_a` and `exactly_one_b` must be set.",
+			},
+			"exactly_one_b": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"exactly_one_a", "exactly_one_b"},
+				Description:  "Exactly one of `exactly_one_a` and `exactly_one_b` must be set.",
+			},
+
+			// AtLeastOneOf: one or more of the attributes must be set.
+			"at_least_one_a": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				AtLeastOneOf: []string{"at_least_one_a", "at_least_one_b"},
+				Description:  "At least one of `at_least_one_a` and `at_least_one_b` must be set.",
+			},
+			"at_least_one_b": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				AtLeastOneOf: []string{"at_least_one_a", "at_least_one_b"},
+				Description:  "At least one of `at_least_one_a` and `at_least_one_b` must be set.",
+			},
+
+			// RequiredWith: if this attribute is set, then the listed ones must
+			// be too. Listing each other means 'both or neither'.
+			"required_with_a": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"required_with_b"},
+				Description:  "Must be set together with `required_with_b`.",
+			},
+			"required_with_b": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"required_with_a"},
+				Description:  "Must be set together with `required_with_a`.",
+			},
+
+			// MinItems/MaxItems limit the number of elements in a list or set.
+			// MinItems only applies when the attribute is actually set.
+			"limited_list": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MinItems:    1,
+				MaxItems:    3,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "A list of between 1 and 3 strings.",
+			},
+
+			// Deprecated attributes still work, but terraform shows the message
+			// as a warning whenever they're used in the configuration.
+			"deprecated_value": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Deprecated:  "Use string_value instead.",
+				Description: "An attribute that is deprecated.",
+			},
+		},
+	}
+}
+
+func resourceAllTypesCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	a := expandAllTypes(d)
+	a.ID = uuid.New().String()
+
+	if err := c.backend.createAllTypes(ctx, a); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(a.ID)
+
+	return resourceAllTypesRead(ctx, d, m)
+}
+
+func resourceAllTypesRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	a, err := c.backend.getAllTypes(ctx, d.Id())
+	if errors.Is(err, errNotFound) {
+		log.Printf(">>> all_types %q not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// d.Set returns an error if the value doesn't match the schema (e.g. a
+	// map[string]string given for a map of integers), which for the simple
+	// types is very unlikely and so is usually ignored. We check every one
+	// here because catching that kind of mistake is the point of this
+	// resource.
+	values := map[string]any{
+		"bool_value":        a.BoolValue,
+		"float_value":       a.FloatValue,
+		"int_with_default":  a.IntWithDefault,
+		"string_value":      a.StringValue,
+		"string_map":        a.StringMap,
+		"int_map":           a.IntMap,
+		"float_map":         a.FloatMap,
+		"bool_map":          a.BoolMap,
+		"string_set":        a.StringSet,
+		"int_set":           a.IntSet,
+		"block_set":         flattenAllTypesBlockSet(a.BlockSet),
+		"nested":            flattenAllTypesNested(a.Nested),
+		"optional_computed": a.OptionalComputed,
+		"conflicts_a":       a.ConflictsA,
+		"conflicts_b":       a.ConflictsB,
+		"exactly_one_a":     a.ExactlyOneA,
+		"exactly_one_b":     a.ExactlyOneB,
+		"at_least_one_a":    a.AtLeastOneA,
+		"at_least_one_b":    a.AtLeastOneB,
+		"required_with_a":   a.RequiredWithA,
+		"required_with_b":   a.RequiredWithB,
+		"limited_list":      a.LimitedList,
+		"deprecated_value":  a.DeprecatedValue,
+	}
+
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+
+	return diags
+}
+
+func resourceAllTypesUpdate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	a := expandAllTypes(d)
+	a.ID = d.Id()
+
+	if err := c.backend.updateAllTypes(ctx, a); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceAllTypesRead(ctx, d, m)
+}
+
+func resourceAllTypesDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	err := c.backend.deleteAllTypes(ctx, d.Id())
+	if err != nil && !errors.Is(err, errNotFound) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// expandAllTypes converts the configuration into the backend's
+// representation. The comments show what type d.Get returns for each kind
+// of attribute.
+func expandAllTypes(d *schema.ResourceData) allTypes {
+	a := allTypes{
+		// Primitives come back as the matching Go type.
+		BoolValue:      d.Get("bool_value").(bool),
+		FloatValue:     d.Get("float_value").(float64),
+		IntWithDefault: d.Get("int_with_default").(int),
+		StringValue:    d.Get("string_value").(string),
+
+		OptionalComputed: d.Get("optional_computed").(string),
+		ConflictsA:       d.Get("conflicts_a").(string),
+		ConflictsB:       d.Get("conflicts_b").(string),
+		ExactlyOneA:      d.Get("exactly_one_a").(string),
+		ExactlyOneB:      d.Get("exactly_one_b").(string),
+		AtLeastOneA:      d.Get("at_least_one_a").(string),
+		AtLeastOneB:      d.Get("at_least_one_b").(string),
+		RequiredWithA:    d.Get("required_with_a").(string),
+		RequiredWithB:    d.Get("required_with_b").(string),
+		DeprecatedValue:  d.Get("deprecated_value").(string),
+
+		StringMap: make(map[string]string),
+		IntMap:    make(map[string]int),
+		FloatMap:  make(map[string]float64),
+		BoolMap:   make(map[string]bool),
+	}
+
+	// Maps are always map[string]any, whatever the type of the values.
+	for k, v := range d.Get("string_map").(map[string]any) {
+		a.StringMap[k] = v.(string)
+	}
+	for k, v := range d.Get("int_map").(map[string]any) {
+		a.IntMap[k] = v.(int)
+	}
+	for k, v := range d.Get("float_map").(map[string]any) {
+		a.FloatMap[k] = v.(float64)
+	}
+	for k, v := range d.Get("bool_map").(map[string]any) {
+		a.BoolMap[k] = v.(bool)
+	}
+
+	// Sets are a *schema.Set, and List() gives their elements as a []any.
+	for _, v := range d.Get("string_set").(*schema.Set).List() {
+		a.StringSet = append(a.StringSet, v.(string))
+	}
+	for _, v := range d.Get("int_set").(*schema.Set).List() {
+		a.IntSet = append(a.IntSet, v.(int))
+	}
+	// ...and blocks are a map[string]any per element.
+	for _, v := range d.Get("block_set").(*schema.Set).List() {
+		kv := v.(map[string]any)
+		a.BlockSet = append(a.BlockSet, allTypesKeyValue{
+			Key:   kv["key"].(string),
+			Value: kv["value"].(string),
+		})
+	}
+
+	// Lists are a []any.
+	for _, v := range d.Get("limited_list").([]any) {
+		a.LimitedList = append(a.LimitedList, v.(string))
+	}
+
+	// Nested blocks are the same types all the way down.
+	for _, v := range d.Get("nested").([]any) {
+		n := v.(map[string]any)
+		nested := allTypesNested{Name: n["name"].(string)}
+		for _, v := range n["level_two"].([]any) {
+			l2 := v.(map[string]any)
+			levelTwo := allTypesLevelTwo{Name: l2["name"].(string)}
+			for _, v := range l2["level_three"].(*schema.Set).List() {
+				l3 := v.(map[string]any)
+				levelTwo.LevelThree = append(levelTwo.LevelThree, allTypesLevelThree{
+					Value: l3["value"].(string),
+				})
+			}
+			nested.LevelTwo = append(nested.LevelTwo, levelTwo)
+		}
+		a.Nested = append(a.Nested, nested)
+	}
+
+	return a
+}
+
+// flattenAllTypesBlockSet converts block_set into a form d.Set understands.
+// A set can be given to d.Set as a []any, it doesn't need to be a *schema.Set.
+func flattenAllTypesBlockSet(kvs []allTypesKeyValue) []any {
+	result := make([]any, 0, len(kvs))
+	for _, kv := range kvs {
+		result = append(result, map[string]any{
+			"key":   kv.Key,
+			"value": kv.Value,
+		})
+	}
+	return result
+}
+
+// flattenAllTypesNested converts nested into a form d.Set understands.
+func flattenAllTypesNested(nested []allTypesNested) []any {
+	result := make([]any, 0, len(nested))
+	for _, n := range nested {
+		levelTwo := make([]any, 0, len(n.LevelTwo))
+		for _, l2 := range n.LevelTwo {
+			levelThree := make([]any, 0, len(l2.LevelThree))
+			for _, l3 := range l2.LevelThree {
+				levelThree = append(levelThree, map[string]any{
+					"value": l3.Value,
+				})
+			}
+			levelTwo = append(levelTwo, map[string]any{
+				"name":        l2.Name,
+				"level_three": levelThree,
+			})
+		}
+		result = append(result, map[string]any{
+			"name":      n.Name,
+			"level_two": levelTwo,
+		})
+	}
+	return result
+}
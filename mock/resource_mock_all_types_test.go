@@ -0,0 +1,333 @@
+package mock
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+// testAllTypesConfig returns a mock_all_types resource with the given
+// attributes.
+func testAllTypesConfig(attributes string) string {
+	return `
+resource "mock_all_types" "test" {
+` + attributes + `
+}
+`
+}
+
+func TestResourceAllTypes(t *testing.T) {
+	// Every attribute that can be set at the same time as the others.
+	config := testAllTypesConfig(`
+  bool_value       = true
+  float_value      = 3.14
+  int_with_default = 7
+  string_value     = "string"
+
+  string_map = { a = "one", b = "two" }
+  int_map    = { a = 1, b = 2 }
+  float_map  = { a = 1.5, b = 2.5 }
+  bool_map   = { a = true, b = false }
+
+  string_set = ["b", "a", "c"]
+  int_set    = [3, 1, 2]
+
+  block_set {
+    key   = "b"
+    value = "two"
+  }
+  block_set {
+    key = "a"
+  }
+
+  nested {
+    name = "one"
+
+    level_two {
+      name = "two"
+
+      level_three {
+        value = "x"
+      }
+      level_three {
+        value = "y"
+      }
+    }
+    level_two {
+      name = "three"
+    }
+  }
+
+  optional_computed = "configured"
+  conflicts_a       = "a"
+  exactly_one_b     = "b"
+  at_least_one_a    = "a"
+  at_least_one_b    = "b"
+  required_with_a   = "a"
+  required_with_b   = "b"
+  limited_list      = ["one", "two", "three"]
+  deprecated_value  = "deprecated"
+`)
+
+	unitTest(t, resource.TestCase{
+		Steps: []resource.TestStep{
+			{
+				// NOTE: Every step that applies a configuration is followed
+				// by a plan, which has to be empty, so this step alone
+				// checks that every attribute survives the round trip
+				// through the backend.
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("mock_all_types.test", "float_value", "3.14"),
+					resource.TestCheckResourceAttr("mock_all_types.test", "int_map.b", "2"),
+					resource.TestCheckResourceAttr("mock_all_types.test", "bool_map.b", "false"),
+					resource.TestCheckTypeSetElemAttr("mock_all_types.test", "int_set.*", "3"),
+					resource.TestCheckTypeSetElemNestedAttrs("mock_all_types.test", "block_set.*", map[string]string{"key": "a", "value": ""}),
+					resource.TestCheckResourceAttr("mock_all_types.test", "nested.0.level_two.1.name", "three"),
+					resource.TestCheckTypeSetElemNestedAttrs("mock_all_types.test", "nested.0.level_two.0.level_three.*", map[string]string{"value": "y"}),
+				),
+			},
+			{
+				Config:   config,
+				PlanOnly: true,
+			},
+			{
+				// Importing reads everything back from the backend, which
+				// has to match what was applied.
+				Config:            config,
+				ResourceName:      "mock_all_types.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// Removing the optional attributes leaves the defaults.
+				Config: testAllTypesConfig(`
+  exactly_one_a  = "a"
+  at_least_one_b = "b"
+`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("mock_all_types.test", "int_with_default", "42"),
+					resource.TestCheckResourceAttr("mock_all_types.test", "string_map.%", "0"),
+					resource.TestCheckResourceAttr("mock_all_types.test", "block_set.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceAllTypes_validation(t *testing.T) {
+	// NOTE: Terraform wraps long errors to fit the terminal, hence the \s+.
+	cases := []struct {
+		name        string
+		attributes  string
+		expectError string
+	}{
+		{
+			name: "ConflictsWith",
+			attributes: `
+  exactly_one_a  = "a"
+  at_least_one_a = "a"
+  conflicts_a    = "a"
+  conflicts_b    = "b"
+`,
+			expectError: `"conflicts_a":\s+conflicts\s+with\s+conflicts_b`,
+		},
+		{
+			name: "ExactlyOneOf with both",
+			attributes: `
+  exactly_one_a  = "a"
+  exactly_one_b  = "b"
+  at_least_one_a = "a"
+`,
+			expectError: `only\s+one\s+of\s+` + "`exactly_one_a,exactly_one_b`" + `\s+can\s+be\s+specified`,
+		},
+		{
+			name: "ExactlyOneOf with neither",
+			attributes: `
+  at_least_one_a = "a"
+`,
+			expectError: `one\s+of\s+` + "`exactly_one_a,exactly_one_b`" + `\s+must\s+be\s+specified`,
+		},
+		{
+			name: "AtLeastOneOf",
+			attributes: `
+  exactly_one_a = "a"
+`,
+			expectError: `one\s+of\s+` + "`at_least_one_a,at_least_one_b`" + `\s+must\s+be\s+specified`,
+		},
+		{
+			name: "RequiredWith",
+			attributes: `
+  exactly_one_a   = "a"
+  at_least_one_a  = "a"
+  required_with_a = "a"
+`,
+			expectError: `all\s+of\s+` + "`required_with_a,required_with_b`" + `\s+must\s+be\s+specified`,
+		},
+		{
+			name: "MaxItems",
+			attributes: `
+  exactly_one_a  = "a"
+  at_least_one_a = "a"
+  limited_list   = ["one", "two", "three", "four"]
+`,
+			expectError: `Too\s+many\s+list\s+items`,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			unitTest(t, resource.TestCase{
+				Steps: []resource.TestStep{
+					{
+						Config:      testAllTypesConfig(c.attributes),
+						PlanOnly:    true,
+						ExpectError: regexp.MustCompile(c.expectError),
+					},
+				},
+			})
+		})
+	}
+}
+
+// testAllTypesAttributes are every attribute of mock_all_types that can be
+// set at the same time as the others, for tests that use testGRPCProvider.
+func testAllTypesAttributes() map[string]any {
+	return map[string]any{
+		"bool_value":       true,
+		"float_value":      3.14,
+		"int_with_default": 7,
+		"string_value":     "string",
+
+		"string_map": map[string]string{"a": "one", "b": "two"},
+		"int_map":    map[string]int{"a": 1, "b": 2},
+		"float_map":  map[string]float64{"a": 1.5, "b": 2.5},
+		"bool_map":   map[string]bool{"a": true, "b": false},
+
+		"string_set": []string{"b", "a", "c"},
+		"int_set":    []int{3, 1, 2},
+
+		"block_set": []map[string]any{
+			{"key": "b", "value": "two"},
+			{"key": "a"},
+		},
+
+		"nested": []map[string]any{{
+			"name": "one",
+			"level_two": []map[string]any{
+				{
+					"name":        "two",
+					"level_three": []map[string]any{{"value": "x"}, {"value": "y"}},
+				},
+				{"name": "three"},
+			},
+		}},
+
+		"optional_computed": "configured",
+		"conflicts_a":       "a",
+		"exactly_one_b":     "b",
+		"at_least_one_a":    "a",
+		"at_least_one_b":    "b",
+		"required_with_a":   "a",
+		"required_with_b":   "b",
+		"limited_list":      []string{"one", "two", "three"},
+		"deprecated_value":  "deprecated",
+	}
+}
+
+func TestResourceAllTypes_roundTrip(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	// An empty plan after the apply means every attribute survived the round
+	// trip through the backend, and so does importing it.
+	config := testAllTypesAttributes()
+	state := p.apply("mock_all_types", cty.NilVal, config)
+	p.planEmpty("mock_all_types", state, config)
+	if imported := p.importState("mock_all_types", state.GetAttr("id").AsString()); !imported.RawEquals(state) {
+		t.Errorf("expected the imported state to be:\n%#v\ngot:\n%#v", state, imported)
+	}
+
+	// Removing the optional attributes leaves the defaults.
+	config = map[string]any{"exactly_one_a": "a", "at_least_one_b": "b"}
+	state = p.apply("mock_all_types", state, config)
+	p.planEmpty("mock_all_types", state, config)
+	if got := state.GetAttr("int_with_default"); !got.RawEquals(cty.NumberIntVal(42)) {
+		t.Errorf("expected int_with_default to be 42, got %#v", got)
+	}
+	// ...apart from an Optional+Computed attribute, which keeps the value it
+	// had, as terraform can't tell it from one the backend chose.
+	if got := state.GetAttr("optional_computed"); !got.RawEquals(cty.StringVal("configured")) {
+		t.Errorf("expected optional_computed to be kept, got %#v", got)
+	}
+	for _, attr := range []string{"string_map", "block_set", "nested"} {
+		if got := state.GetAttr(attr); !got.IsNull() && got.LengthInt() != 0 {
+			t.Errorf("expected %s to be empty, got %#v", attr, got)
+		}
+	}
+}
+
+func TestResourceAllTypes_validateConfig(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	cases := []struct {
+		name        string
+		attributes  map[string]any
+		summary     string
+		expectError string
+	}{
+		{
+			name:        "ConflictsWith",
+			attributes:  map[string]any{"exactly_one_a": "a", "at_least_one_a": "a", "conflicts_a": "a", "conflicts_b": "b"},
+			summary:     "Conflicting configuration arguments",
+			expectError: `"conflicts_a": conflicts with conflicts_b`,
+		},
+		{
+			name:        "ExactlyOneOf with both",
+			attributes:  map[string]any{"exactly_one_a": "a", "exactly_one_b": "b", "at_least_one_a": "a"},
+			summary:     "Invalid combination of arguments",
+			expectError: "only one of `exactly_one_a,exactly_one_b` can be specified",
+		},
+		{
+			name:        "ExactlyOneOf with neither",
+			attributes:  map[string]any{"at_least_one_a": "a"},
+			summary:     "Invalid combination of arguments",
+			expectError: "one of `exactly_one_a,exactly_one_b` must be specified",
+		},
+		{
+			name:        "AtLeastOneOf",
+			attributes:  map[string]any{"exactly_one_a": "a"},
+			summary:     "Missing required argument",
+			expectError: "one of `at_least_one_a,at_least_one_b` must be specified",
+		},
+		{
+			name:        "RequiredWith",
+			attributes:  map[string]any{"exactly_one_a": "a", "at_least_one_a": "a", "required_with_a": "a"},
+			summary:     "Missing required argument",
+			expectError: "all of `required_with_a,required_with_b` must be specified",
+		},
+		{
+			name:        "MaxItems",
+			attributes:  map[string]any{"exactly_one_a": "a", "at_least_one_a": "a", "limited_list": []string{"one", "two", "three", "four"}},
+			summary:     "Too many list items",
+			expectError: "Attribute limited_list supports 3 item maximum, but config has 4 declared",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			testExpectError(t, p.validate("mock_all_types", c.attributes), "^"+c.summary+"$", regexp.QuoteMeta(c.expectError))
+		})
+	}
+
+	// A deprecated attribute still works, with a warning.
+	diags := p.validate("mock_all_types", map[string]any{"exactly_one_a": "a", "at_least_one_a": "a", "deprecated_value": "x"})
+	if testHasError(diags) || len(diags) != 1 || diags[0].Severity != tfprotov5.DiagnosticSeverityWarning {
+		t.Errorf("expected a single warning for deprecated_value, got %v", diags)
+	}
+}
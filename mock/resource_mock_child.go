@@ -0,0 +1,132 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceChild belongs to a mock_parent. The backend rejects children whose
+// parent doesn't exist, see backend_parent.go for the details.
+func resourceChild() *schema.Resource {
+	return &schema.Resource{
+		Description: "A child object that belongs to a mock_parent.",
+
+		CreateContext: resourceChildCreate,
+		ReadContext:   resourceChildRead,
+		UpdateContext: resourceChildUpdate,
+		DeleteContext: resourceChildDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"parent_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The ID of the mock_parent this child belongs to.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the child.",
+			},
+		},
+	}
+}
+
+// invalidParentDiagnostic explains the most likely cause of a child pointing
+// at a parent that doesn't exist.
+func invalidParentDiagnostic(err error) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  "Parent does not exist",
+		Detail: err.Error() + "\n\nIf the parent is managed by terraform, make sure `parent_id` " +
+			"references it (e.g. `parent_id = mock_parent.example.id`) so that terraform knows to " +
+			"create the parent first.",
+		AttributePath: cty.GetAttrPath("parent_id"),
+	}}
+}
+
+func resourceChildCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	ch := child{
+		ID:       uuid.New().String(),
+		ParentID: d.Get("parent_id").(string),
+		Name:     d.Get("name").(string),
+	}
+	err := c.backend.createChild(ctx, ch)
+	if errors.Is(err, errInvalidParent) {
+		return invalidParentDiagnostic(err)
+	}
+	if errors.Is(err, errAlreadyExists) {
+		return alreadyExistsDiagnostic("mock_child", err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(ch.ID)
+
+	return resourceChildRead(ctx, d, m)
+}
+
+func resourceChildRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	ch, err := c.backend.getChild(ctx, d.Id())
+	if errors.Is(err, errNotFound) {
+		log.Printf(">>> child %q not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.Set("parent_id", ch.ParentID)
+	d.Set("name", ch.Name)
+
+	return nil
+}
+
+func resourceChildUpdate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	// Unlike a lot of real APIs, the backend allows a child to be moved to a
+	// different parent, so parent_id doesn't need to be ForceNew.
+	err := c.backend.updateChild(ctx, child{
+		ID:       d.Id(),
+		ParentID: d.Get("parent_id").(string),
+		Name:     d.Get("name").(string),
+	})
+	if errors.Is(err, errInvalidParent) {
+		return invalidParentDiagnostic(err)
+	}
+	if errors.Is(err, errAlreadyExists) {
+		return nameTakenDiagnostic("mock_child", err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceChildRead(ctx, d, m)
+}
+
+func resourceChildDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	err := c.backend.deleteChild(ctx, d.Id())
+	if err != nil && !errors.Is(err, errNotFound) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestResourceChild_parentMustExist(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	_, diags := p.tryApply("mock_child", cty.NilVal, map[string]any{"name": "orphan", "parent_id": "missing"})
+	testExpectError(t, diags, "^Parent does not exist$", `^invalid parent: parent "missing" does not exist(?s).*parent_id = mock_parent.example.id`)
+
+	parent := p.apply("mock_parent", cty.NilVal, map[string]any{"name": "parent"})
+	config := map[string]any{"name": "child", "parent_id": parent.GetAttr("id").AsString()}
+	child := p.apply("mock_child", cty.NilVal, config)
+
+	// Moving the child to a parent that doesn't exist fails the same way,
+	// and leaves it where it was.
+	_, diags = p.tryApply("mock_child", child, map[string]any{"name": "child", "parent_id": "missing"})
+	testExpectError(t, diags, "^Parent does not exist$", `^invalid parent: parent "missing" does not exist`)
+	p.planEmpty("mock_child", p.read("mock_child", child), config)
+}
+
+func TestResourceParent_deleteWithChildren(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	parent := p.apply("mock_parent", cty.NilVal, map[string]any{"name": "parent"})
+	child := p.apply("mock_child", cty.NilVal, map[string]any{"name": "child", "parent_id": parent.GetAttr("id").AsString()})
+
+	// This is what terraform does when it doesn't know about the dependency,
+	// e.g. because parent_id is hardcoded.
+	diags := p.tryDestroy("mock_parent", parent)
+	testExpectError(t, diags, "^Cannot delete parent with children$", `^conflict: parent ".+" still has 1 child\(ren\): `+child.GetAttr("id").AsString()+`(?s).*depends_on`)
+	if state := p.read("mock_parent", parent); state.IsNull() {
+		t.Fatal("expected the parent to still exist")
+	}
+
+	p.destroy("mock_child", child)
+	p.destroy("mock_parent", parent)
+	if state := p.read("mock_parent", parent); !state.IsNull() {
+		t.Fatalf("expected the parent to be gone, got %#v", state)
+	}
+}
@@ -0,0 +1,92 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceConfigInspector reports how the provider sees each of its input
+// attributes, both at plan time and at apply time (see config_inspector.go).
+//
+// It doesn't talk to the backend at all, there's nothing to store other than
+// what ends up in the terraform state.
+func resourceConfigInspector() *schema.Resource {
+	s := configInspectorInputs()
+
+	// NOTE: Unknown values only exist during the plan. By the time CREATE or
+	// UPDATE is called every value in the config is known, so the only place
+	// we can see them is CustomizeDiff.
+	s["plan_inspection"] = configInspectionSchema("How each input attribute looked at plan time (from CustomizeDiff).")
+	s["apply_inspection"] = configInspectionSchema("How each input attribute looked at apply time (from CREATE/UPDATE).")
+
+	return &schema.Resource{
+		Description: "Records whether each input attribute was null, empty, unknown or set, as seen by the different ResourceData/ResourceDiff accessors.",
+
+		CreateContext: resourceConfigInspectorCreate,
+		ReadContext:   resourceConfigInspectorRead,
+		UpdateContext: resourceConfigInspectorUpdate,
+		DeleteContext: resourceConfigInspectorDelete,
+
+		CustomizeDiff: resourceConfigInspectorCustomizeDiff,
+
+		Schema: s,
+	}
+}
+
+func resourceConfigInspectorCustomizeDiff(_ context.Context, d *schema.ResourceDiff, _ any) error {
+	var inputs []string
+	for name := range configInspectorInputs() {
+		inputs = append(inputs, name)
+	}
+
+	// Only inspect when something is actually going to happen, otherwise the
+	// inspection itself would show up as a change on every plan.
+	if d.Id() != "" && !d.HasChanges(inputs...) {
+		return nil
+	}
+
+	// NOTE: Whatever is planned here must be exactly what ends up in state,
+	// so CREATE/UPDATE must leave plan_inspection alone. Setting it again at
+	// apply time (where nothing is unknown any more) would produce a different
+	// value and terraform would report "Provider produced inconsistent result
+	// after apply".
+	if err := d.SetNew("plan_inspection", inspectConfig(d)); err != nil {
+		return err
+	}
+	return d.SetNewComputed("apply_inspection")
+}
+
+func resourceConfigInspectorCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	d.SetId(uuid.New().String())
+
+	if err := d.Set("apply_inspection", inspectConfig(d)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceConfigInspectorRead(ctx, d, m)
+}
+
+func resourceConfigInspectorRead(_ context.Context, _ *schema.ResourceData, _ any) diag.Diagnostics {
+	// There is no remote object, so whatever is in state is the truth.
+	//
+	// NOTE: GetRawConfig returns a null object here. READ is also called
+	// during a refresh, when terraform doesn't send the configuration, so
+	// there's nothing useful to inspect.
+	return nil
+}
+
+func resourceConfigInspectorUpdate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	if err := d.Set("apply_inspection", inspectConfig(d)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceConfigInspectorRead(ctx, d, m)
+}
+
+func resourceConfigInspectorDelete(_ context.Context, d *schema.ResourceData, _ any) diag.Diagnostics {
+	d.SetId("")
+	return nil
+}
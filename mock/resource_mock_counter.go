@@ -0,0 +1,112 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceCounter manages a named counter in the backend. On its own it isn't
+// very interesting, it exists so that mock_counter_member resources have some
+// shared state to fight over (see resource_mock_counter_member.go).
+func resourceCounter() *schema.Resource {
+	return &schema.Resource{
+		Description: "A named counter that mock_counter_member resources allocate sequential slots from.",
+
+		CreateContext: resourceCounterCreate,
+		ReadContext:   resourceCounterRead,
+		DeleteContext: resourceCounterDelete,
+
+		// The counter name is its ID, so `terraform import mock_counter.x <name>`
+		// only needs to copy the ID across and let READ do the rest.
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The unique name of the counter.",
+			},
+			"initial_value": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     0,
+				Description: "The value the counter starts at. The first allocated slot is `initial_value + 1`.",
+			},
+			"value": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The current value of the counter (i.e. the last slot allocated).",
+			},
+		},
+	}
+}
+
+func resourceCounterCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	name := d.Get("name").(string)
+	err := c.backend.createCounter(ctx, counter{
+		Name:  name,
+		Value: d.Get("initial_value").(int),
+	})
+	if errors.Is(err, errAlreadyExists) {
+		return alreadyExistsDiagnostic("mock_counter", err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(name)
+
+	return resourceCounterRead(ctx, d, m)
+}
+
+func resourceCounterRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	ctr, err := c.backend.getCounter(ctx, d.Id())
+	if errors.Is(err, errNotFound) {
+		// The counter was deleted outside of terraform. Removing the ID from
+		// state tells terraform the resource is gone and needs recreating.
+		log.Printf(">>> counter %q not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.Set("name", ctr.Name)
+	d.Set("value", ctr.Value)
+
+	return nil
+}
+
+func resourceCounterDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	err := c.backend.deleteCounter(ctx, d.Id())
+	if errors.Is(err, errConflict) {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Cannot delete counter with members",
+			Detail: err.Error() + "\n\nTerraform destroys resources in the reverse order of their " +
+				"dependencies. If the members are managed by terraform, make sure they reference " +
+				"this counter (e.g. `counter = mock_counter.example.name`) rather than a hardcoded name, " +
+				"or add an explicit `depends_on`.",
+		}}
+	}
+	if err != nil && !errors.Is(err, errNotFound) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// counterAllocationLatency simulates the network round trip between reading
+// the counter and writing it back. Without it the window for the race is so
+// small that it would rarely be hit when experimenting locally.
+const counterAllocationLatency = 200 * time.Millisecond
+
+// resourceCounterMember allocates the next sequential slot from a counter.
+//
+// This is a reproduction of a class of bug that bites a lot of real providers:
+//
+//	resource "mock_counter" "c" {
+//	  name = "example"
+//	}
+//
+//	resource "mock_counter_member" "m" {
+//	  count   = 10
+//	  counter = mock_counter.c.name
+//	}
+//
+// Terraform will create all ten members in parallel. Each CREATE reads the
+// counter, adds one and writes it back. If two of them read the counter
+// before either has written it back, they'll both try to claim the same slot
+// and the backend will reject the second one.
+//
+// The fix is a provider-level mutex keyed on the counter name, so only one
+// allocation per counter can be in-flight at a time (allocations from
+// different counters still run in parallel). Set `disable_locking = true` in
+// the provider block to see what happens without it.
+func resourceCounterMember() *schema.Resource {
+	return &schema.Resource{
+		Description: "Allocates the next sequential slot from a mock_counter.",
+
+		CreateContext: resourceCounterMemberCreate,
+		ReadContext:   resourceCounterMemberRead,
+		DeleteContext: resourceCounterMemberDelete,
+
+		Schema: map[string]*schema.Schema{
+			"counter": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The name of the counter to allocate a slot from.",
+			},
+			"slot": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The slot allocated to this member.",
+			},
+		},
+	}
+}
+
+func resourceCounterMemberCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	name := d.Get("counter").(string)
+
+	// Everything between here and the deferred unlock is a read-modify-write
+	// of the shared counter, so it must not interleave with another
+	// allocation from the same counter.
+	defer c.lock("counter/" + name)()
+
+	ctr, err := c.backend.getCounter(ctx, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return diag.FromErr(ctx.Err())
+	case <-time.After(counterAllocationLatency):
+	}
+
+	slot := ctr.Value + 1
+	log.Printf(">>> allocating slot %d from counter %q", slot, name)
+
+	// NOTE: The member is created before the counter is bumped, so that a
+	// clash over the slot leaves the counter as it was. Were it the other way
+	// round, the loser of a race would have bumped the counter to a slot it
+	// never got.
+	member := counterMember{
+		ID:      uuid.New().String(),
+		Counter: name,
+		Slot:    slot,
+	}
+	if err := c.backend.createCounterMember(ctx, member); err != nil {
+		if !errors.Is(err, errConflict) {
+			return diag.FromErr(err)
+		}
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Failed to allocate counter slot",
+			Detail: err.Error() + "\n\nThis usually means another allocation from the same counter " +
+				"raced with this one. Is `disable_locking` set in the provider configuration?",
+		}}
+	}
+
+	if err := c.backend.setCounterValue(ctx, name, slot); err != nil {
+		// Hand the slot back, or the next allocation would clash with it.
+		if err := c.backend.deleteCounterMember(ctx, member.ID); err != nil {
+			log.Printf(">>> failed to delete counter member %q after failing to update the counter: %s", member.ID, err)
+		}
+		return diag.FromErr(err)
+	}
+
+	d.SetId(member.ID)
+
+	return resourceCounterMemberRead(ctx, d, m)
+}
+
+func resourceCounterMemberRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	member, err := c.backend.getCounterMember(ctx, d.Id())
+	if errors.Is(err, errNotFound) {
+		log.Printf(">>> counter member %q not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.Set("counter", member.Counter)
+	d.Set("slot", member.Slot)
+
+	return nil
+}
+
+func resourceCounterMemberDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	// Slots are never handed back to the counter, so deleting a member
+	// doesn't need the lock.
+	err := c.backend.deleteCounterMember(ctx, d.Id())
+	if err != nil && !errors.Is(err, errNotFound) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
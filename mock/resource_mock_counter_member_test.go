@@ -0,0 +1,123 @@
+package mock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+)
+
+// testCreateCounterMembers creates n members of the counter at once, like
+// terraform does with `count = n`, and returns the state and diagnostics of
+// each.
+func testCreateCounterMembers(p *testGRPCProvider, counter string, n int) ([]cty.Value, [][]*tfprotov5.Diagnostic) {
+	states := make([]cty.Value, n)
+	diags := make([][]*tfprotov5.Diagnostic, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			states[i], diags[i] = p.tryApply("mock_counter_member", cty.NilVal, map[string]any{"counter": counter})
+		}(i)
+	}
+	wg.Wait()
+	return states, diags
+}
+
+func TestResourceCounterMember_concurrentCreate(t *testing.T) {
+	const n = 5
+
+	t.Run("locking", func(t *testing.T) {
+		testProviderEnv(t)
+		p := newTestGRPCProvider(t, nil)
+		counter := p.apply("mock_counter", cty.NilVal, map[string]any{"name": "locking"})
+
+		states, diags := testCreateCounterMembers(p, "locking", n)
+		var slots []int
+		for i := range states {
+			p.check("create member", diags[i])
+			slot, _ := states[i].GetAttr("slot").AsBigFloat().Int64()
+			slots = append(slots, int(slot))
+		}
+		sort.Ints(slots)
+		for i, slot := range slots {
+			if slot != i+1 {
+				t.Fatalf("expected slots 1 to %d, got %v", n, slots)
+			}
+		}
+
+		ctr, err := p.client().backend.getCounter(context.Background(), "locking")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctr.Value != n {
+			t.Errorf("expected the counter to be %d, got %d", n, ctr.Value)
+		}
+
+		// The counter can't go while its members are still there.
+		testExpectError(t, p.tryDestroy("mock_counter", counter), "^Cannot delete counter with members$", `still has 5 member\(s\)`)
+		for _, state := range states {
+			p.destroy("mock_counter_member", state)
+		}
+		p.destroy("mock_counter", counter)
+	})
+
+	t.Run("disable_locking", func(t *testing.T) {
+		testProviderEnv(t)
+		p := newTestGRPCProvider(t, map[string]any{"disable_locking": true})
+		p.apply("mock_counter", cty.NilVal, map[string]any{"name": "unlocked"})
+
+		// Every allocation reads the counter before any of them writes it
+		// back, so they all go for slot 1, and only one of them gets it.
+		states, diags := testCreateCounterMembers(p, "unlocked", n)
+		var created int
+		for i := range states {
+			if !testHasError(diags[i]) {
+				created++
+				continue
+			}
+			testExpectError(t, diags[i], "^Failed to allocate counter slot$", `conflict: slot 1 of counter "unlocked" is already allocated(?s).*disable_locking`)
+			if !states[i].IsNull() {
+				t.Errorf("expected a member that failed to allocate a slot to have no state, got %#v", states[i])
+			}
+		}
+		if created != 1 {
+			t.Errorf("expected exactly one member to be created, got %d", created)
+		}
+
+		ctr, err := p.client().backend.getCounter(context.Background(), "unlocked")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctr.Value != 1 {
+			t.Errorf("expected the counter to be 1, got %d", ctr.Value)
+		}
+	})
+
+	t.Run("slot taken", func(t *testing.T) {
+		testProviderEnv(t)
+		p := newTestGRPCProvider(t, nil)
+		p.apply("mock_counter", cty.NilVal, map[string]any{"name": "taken"})
+
+		// A member that was created behind the provider's back has the next
+		// slot, so the allocation fails, and must leave the counter alone.
+		ctx := context.Background()
+		if err := p.client().backend.createCounterMember(ctx, counterMember{ID: "elsewhere", Counter: "taken", Slot: 1}); err != nil {
+			t.Fatal(err)
+		}
+		_, diags := p.tryApply("mock_counter_member", cty.NilVal, map[string]any{"counter": "taken"})
+		testExpectError(t, diags, "^Failed to allocate counter slot$", "slot 1 of counter \"taken\" is already allocated to elsewhere")
+
+		ctr, err := p.client().backend.getCounter(ctx, "taken")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctr.Value != 0 {
+			t.Errorf("expected the counter to be left at 0, got %d", ctr.Value)
+		}
+	})
+}
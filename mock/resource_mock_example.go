@@ -1,11 +1,16 @@
 package mock
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func resourceExample() *schema.Resource {
@@ -15,139 +20,322 @@ func resourceExample() *schema.Resource {
 		// When terraform reads the state file, if a resource doesn't exist, then a
 		// CREATE operation will be started (otherwise an UPDATE operation).
 		//
-		Create: resourceCreate,
-		Read:   resourceRead,
-		Update: resourceUpdate,
-		Delete: resourceDelete,
+		// NOTE:
+		// We use the 'Context' variants of the CRUD functions so that we can
+		// pass the context through to anything that waits on the backend
+		// (which means a Ctrl-C from the user stops us waiting).
+		//
+		CreateContext: resourceCreate,
+		ReadContext:   resourceRead,
+		UpdateContext: resourceUpdate,
+		DeleteContext: resourceDelete,
+
+		// An existing object can be brought under terraform's management with
+		// `terraform import mock_example.<name> <id>`. All the importer needs to
+		// do is store the ID, and terraform will then call READ to populate the
+		// rest of the state.
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
 
-		// Resource Schema
+		// Whenever the structure of the state changes in a way that existing
+		// state files can't just be read with the new schema, the schema
+		// version has to be incremented and a StateUpgrader added that
+		// migrates state from the previous version.
 		//
-		// NOTE:
-		// You must specify either 'optional', 'required', or 'computed' and the
-		// value needs to be set to boolean 'true'.
+		// Version 0: baz was a TypeList.
+		// Version 1: baz is a TypeSet.
 		//
-		// Reference:
-		// https://www.terraform.io/docs/extend/schemas/schema-types.html
-		Schema: map[string]*schema.Schema{
-			"last_updated": {
-				Type:     schema.TypeString,
-				Computed: true,
-			},
-			"not_computed_optional": {
-				Type:     schema.TypeString,
-				Optional: true,
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceExampleV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceExampleStateUpgradeV0,
 			},
-			"not_computed_required": {
-				Type:     schema.TypeString,
-				Required: true,
+		},
+
+		// CustomizeDiff lets us adjust the plan terraform has worked out from the
+		// schema and the configuration (see resourceExampleCustomizeDiff).
+		CustomizeDiff: resourceExampleCustomizeDiff,
+
+		// When the backend is running in async mode, CREATE/UPDATE/DELETE have
+		// to wait for the operation they started to finish. These are the
+		// default limits on how long they'll wait, which a consumer can
+		// override with a 'timeouts' block:
+		//
+		// resource "mock_example" "testing" {
+		//   timeouts {
+		//     create = "30s"
+		//   }
+		// }
+		//
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: resourceExampleSchema(),
+	}
+}
+
+// Resource Schema
+//
+// NOTE:
+// You must specify either 'optional', 'required', or 'computed' and the
+// value needs to be set to boolean 'true'.
+//
+// Reference:
+// https://www.terraform.io/docs/extend/schemas/schema-types.html
+func resourceExampleSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		// The backend requires the name to be unique, and doesn't allow it to
+		// be changed, so changing it in the configuration means terraform has
+		// to replace the object (that's what ForceNew does).
+		//
+		// Rather than a name, the user can give a 'name_prefix' and let the
+		// backend generate a unique name from it. That's the standard escape
+		// hatch for resources that need replacing with create_before_destroy,
+		// where the replacement is created while the original still exists
+		// and so can't have the same name.
+		//
+		// The name is Optional+Computed: when the user doesn't set it, the
+		// plan shows it as '(known after apply)' and the generated name ends
+		// up in state. If neither is set, the backend uses a default prefix.
+		"name": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"name_prefix"},
+		},
+		"name_prefix": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"name"},
+		},
+		"last_updated": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		// The revision of the object we last read from the backend. We send
+		// it back with every update so the backend can reject the update if
+		// someone else has changed the object since.
+		"revision": {
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		// The calls the provider made to the backend during the last create or
+		// update, e.g. ["PUT fields", "PATCH foo: replace /foo/1"].
+		"backend_calls": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
 			},
-			// This attribute is 'required' meaning the consumer of this provider
-			// will need to define the values expected when writing their terraform
-			// HCL code.
-			"foo": {
-				Type:     schema.TypeList,
-				Optional: true,
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"bar": {
-							Type:     schema.TypeList,
-							MaxItems: 1,
-							Required: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"number": {
-										Type:     schema.TypeInt,
-										Optional: true,
-									},
-									"version": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
+		},
+		// Despite its name, not_computed_optional is now Optional+Computed.
+		// When it's omitted the backend assigns a default, and a plain
+		// Optional attribute would then show a diff on every plan (the
+		// config says null, the state says "backend-default").
+		//
+		// Optional+Computed tells terraform that when the config doesn't set
+		// a value, whatever the provider puts in state is fine. The catch is
+		// that removing a value that *was* set no longer shows a diff either,
+		// which the CustomizeDiff function deals with.
+		"not_computed_optional": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Computed: true,
+		},
+		"not_computed_required": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		// This attribute is 'required' meaning the consumer of this provider
+		// will need to define the values expected when writing their terraform
+		// HCL code.
+		"foo": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					// An optional identifier for the entry, which lets the
+					// provider tell the backend about changes to individual
+					// entries even when others are added or removed (see
+					// foo_diff.go).
+					"key": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"bar": {
+						Type:     schema.TypeList,
+						MaxItems: 1,
+						Required: true,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								// Defaulted by the backend, like
+								// not_computed_optional.
+								"number": {
+									Type:     schema.TypeInt,
+									Optional: true,
+									Computed: true,
+								},
+								"version": {
+									Type:     schema.TypeString,
+									Computed: true,
 								},
 							},
 						},
 					},
 				},
 			},
-			/*
-				foo example:
+		},
+		/*
+			foo example:
 
-				resource "mock_example" "testing" {
-					foo {
-						bar {
-							number = 1
-						}
+			resource "mock_example" "testing" {
+				foo {
+					bar {
+						number = 1
 					}
-				  foo {
-						bar {
-							number = 2
-						}
+				}
+			  foo {
+					bar {
+						number = 2
 					}
-					foo {
-						bar {
-							number = 3
-						}
+				}
+				foo {
+					bar {
+						number = 3
 					}
 				}
+			}
 
-				OR
+			OR
 
-				resource "mock_example" "testing" {
-					dynamic "foo" {
-						for_each = [{ number = 1 }, { number = 2 }, { number = 3 }]
-						content {
-							bar {
-								number = foo.value.number
-							}
+			resource "mock_example" "testing" {
+				dynamic "foo" {
+					for_each = [{ number = 1 }, { number = 2 }, { number = 3 }]
+					content {
+						bar {
+							number = foo.value.number
 						}
 					}
 				}
-			*/
-			"baz": {
-				Type:     schema.TypeList,
-				Required: true,
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"qux": {
-							Type:     schema.TypeString,
-							Required: true,
-						},
+			}
+		*/
+		// The order of the baz blocks doesn't mean anything to the backend, so
+		// it's a TypeSet rather than a TypeList. With a list, reordering the
+		// blocks in the configuration (or the elements of a dynamic block's
+		// for_each) shows up as a change to every element whose position
+		// moved. With a set, terraform only shows elements that were actually
+		// added or removed.
+		//
+		// Each element of a set is identified by a hash. By default that's a
+		// hash of every attribute in the element, but we provide our own Set
+		// function (see hashExampleBaz) to show how it works.
+		//
+		// NOTE:
+		// baz was originally a TypeList, so changing its type needed the
+		// resource's SchemaVersion bumping and a StateUpgrader adding (see
+		// resourceExampleStateUpgradeV0).
+		"baz": {
+			Type:     schema.TypeSet,
+			Required: true,
+			Set:      hashExampleBaz,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"qux": {
+						Type:     schema.TypeString,
+						Required: true,
 					},
 				},
 			},
-			/*
-				baz example:
+		},
+		/*
+			baz example:
 
-				resource "mock_example" "testing" {
-					baz {
-						qux = "x"
-					}
-					baz {
-						qux = "y"
-					}
-					baz {
-						qux = "z"
-					}
+			resource "mock_example" "testing" {
+				baz {
+					qux = "x"
+				}
+				baz {
+					qux = "y"
 				}
+				baz {
+					qux = "z"
+				}
+			}
 
-				OR
+			OR
 
-				resource "mock_example" "testing" {
-					dynamic "baz" {
-						for_each = [{ qux = "x" }, { qux = "y" }, { qux = "z" }]
-						content {
-							qux = baz.value.qux
-						}
+			resource "mock_example" "testing" {
+				dynamic "baz" {
+					for_each = [{ qux = "x" }, { qux = "y" }, { qux = "z" }]
+					content {
+						qux = baz.value.qux
 					}
 				}
-			*/
+			}
+		*/
+
+		"some_list": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
 
-			"some_list": {
-				Type:     schema.TypeList,
-				Optional: true,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
-				},
+		// Make one of the steps of creating the object fail, and decide what
+		// happens to the partially created object when one does (see
+		// create_steps.go). Neither is sent to the backend, and an unset
+		// create_failure_strategy means "taint".
+		"fail_create_step": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{createStepAttachFoo, createStepApplyBaz}, false),
+		},
+		"create_failure_strategy": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{createFailureTaint, createFailureRollback}, false),
+		},
+		// Similarly, make one of the steps of updating the object fail (see
+		// update_steps.go).
+		"fail_update_step": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{updateStepFields, updateStepFoo, updateStepBaz, updateStepSomeList}, false),
+		},
+
+		// Makes the resource misbehave in one of the ways that causes terraform
+		// to report "Provider produced inconsistent result after apply" (see
+		// inconsistency.go). It's never sent to the backend.
+		"inconsistency_mode": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice(inconsistencyModes, false),
+		},
+
+		// The resource's own tags, which are merged with the provider's
+		// 'default_tags' to give 'tags_all' (see tags.go).
+		"tags": {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"tags_all": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
 			},
 		},
 	}
@@ -157,35 +345,89 @@ func resourceExample() *schema.Resource {
 // won't set anything in the terraform state, with the exception of setting a
 // unique ID that will be used by all the other functions to access the
 // resource data from state.
-func resourceCreate(d *schema.ResourceData, m any) error {
+func resourceCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
 	log.Print("\n\n--- CREATE ---\n\n")
 	log.Printf("\n\n>>> schema.ResourceData: %+v\n\n", d)
 	log.Printf("\n\n>>> meta data: %+v\n\n", m)
 
-	// If this were a real provider, then we'd have an API client that would be
-	// creating, reading, updating, deleting (i.e. CRUD) data.
-	//
-	// c := m.(*some.APIClient)
-
-	foo := d.Get("foo").([]any)
-	log.Printf(">>> foo: %+v (%T)\n", foo, foo)
-
-	// We could do things with foo, e.g. loop over its elements and build up a
-	// data structure to be used as input to an API client to create.
-	//
-	// Remember that "foo" was defined in the schema as "required" meaning the
-	// consumer of this provider would need to provide the values associated with
-	// the foo schema.
+	// The meta parameter is whatever the provider's ConfigureContextFunc
+	// returned, which for us is a *Client wrapping the mock backend.
+	c := m.(*Client)
+
+	// We build up the data structure the API expects from the terraform
+	// configuration (see expandExample for the details).
+	e := expandExample(d, c)
+	injectPlannedValueInconsistency(d, &e)
+	log.Printf(">>> example: %+v\n", e)
+
+	// The backend creates the object without its foo entries and baz
+	// settings, those are added by separate calls afterwards (see
+	// create_steps.go).
+	base := e
+	base.Foo = nil
+	base.Baz = nil
+
+	// The backend responds with an 'operation' rather than the object itself.
+	// The operation's target is the ID the new object will have.
+	op, err := c.backend.createExample(ctx, base)
+	if errors.Is(err, errAlreadyExists) {
+		return withNamePrefixHint(alreadyExistsDiagnostic("mock_example", err))
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
-	// Let's pretend we made an API call and in the response we got back there
-	// was an ID we could use as a unique key in our terraform state file so it
-	// was able to track the resource.
-	//
 	// NOTE:
 	// The mere existence of the ID and lack of error means terraform will
 	// presume the CREATE operation was successful and store the provided "foo"
 	// data the user provided into the local state file.
-	d.SetId("123")
+	//
+	// We set the ID *before* waiting on the operation. If we time out while
+	// waiting, the object may well still be created in the background, and
+	// having the ID in state means terraform marks the resource as 'tainted'
+	// (so it's replaced on the next apply) rather than forgetting about it.
+	d.SetId(op.Target)
+
+	if err := waitForOperation(ctx, c, op, d.Timeout(schema.TimeoutCreate)); err != nil {
+		latest, lookupErr := c.backend.getOperation(ctx, op.ID)
+		if lookupErr == nil && latest.Status == operationFailed {
+			// The operation definitely failed, so nothing was created and
+			// there's nothing for terraform to track.
+			d.SetId("")
+		}
+		return diag.FromErr(err)
+	}
+
+	steps := []exampleStep{
+		{
+			name: createStepAttachFoo,
+			call: func(ctx context.Context, revision int) (*operation, error) {
+				return c.backend.setExampleFoo(ctx, d.Id(), revision, e.Foo)
+			},
+			request: "PUT foo",
+		},
+		{
+			name: createStepApplyBaz,
+			call: func(ctx context.Context, revision int) (*operation, error) {
+				return c.backend.setExampleBaz(ctx, d.Id(), revision, e.Baz)
+			},
+			request: "PUT baz",
+		},
+	}
+
+	// Each step is based on the revision the previous one produced.
+	revision := op.Example.Revision
+	calls := []string{"POST example"}
+	for _, step := range steps {
+		op, err := runExampleStep(ctx, d, c, step, revision, "fail_create_step", d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			d.Set("backend_calls", calls)
+			return createStepFailed(ctx, d, m, step.name, err)
+		}
+		revision = op.Example.Revision
+		calls = append(calls, step.request)
+	}
+	d.Set("backend_calls", calls)
 
 	// We do a READ operation to be sure we get the latest state stored locally.
 	//
@@ -198,7 +440,7 @@ func resourceCreate(d *schema.ResourceData, m any) error {
 	// operation (which itself would have caused an error earlier and failed the
 	// CREATE any way). This way we're ensuring the local state is up-to-date and
 	// doesn't need a refresh.
-	return resourceRead(d, m)
+	return resourceRead(ctx, d, m)
 }
 
 // The READ operation must handle three things: calling out to the API to get
@@ -208,112 +450,135 @@ func resourceCreate(d *schema.ResourceData, m any) error {
 // the latest data into terraform's state file so terraform can identify if
 // there are any differences between what the user has defined and what
 // actually exists in reality.
-func resourceRead(d *schema.ResourceData, m any) error {
+func resourceRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
 	log.Print("\n\n--- READ ---\n\n")
 	log.Printf("\n\n>>> schema.ResourceData: %+v\n\n", d)
 	log.Printf("\n\n>>> meta data: %+v\n\n", m)
 
+	c := m.(*Client)
+
 	// We get the ID we set into terraform state after we had initially created
 	// the resource.
 	resourceID := d.Id()
 	log.Println(">>> resourceID:", resourceID)
 
-	// For the purposes of our 'mock' provider, we don't have an API to get data
-	// from and so I'm going to hardcode data to be set into terraform state. To
-	// do that I have to first get the data out from terraform state.
-	foo := d.Get("foo")
-	log.Printf("\n\n>>> foo: %+v (%T)\n\n", foo, foo)
+	e, err := c.backend.getExample(ctx, resourceID)
+	if errors.Is(err, errNotFound) {
+		// The object no longer exists (e.g. it was deleted outside of
+		// terraform). Clearing the ID tells terraform to remove it from state,
+		// and so the next plan will show it needs to be created again.
+		log.Printf(">>> example %q not found, removing from state", resourceID)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	log.Printf("\n\n>>> example: %+v\n\n", e)
 
-	// I want to set a computed value for the nested 'version' attribute, but to
-	// do that I have to iterate over each parent structure until I reach the
-	// relevant level of the data structure where I can then set a value on the
-	// 'version' attribute.
+	injectReadInconsistency(d, e)
 
-	// In order to loop over foo, we need to cast it to the appropriate type
-	for _, f := range foo.([]any) {
-		f := f.(map[string]any)
-		log.Printf("\n\n>>> f: %+v (%T)\n\n", f, f)
+	d.Set("name", e.Name)
+	d.Set("name_prefix", e.NamePrefix)
+	d.Set("not_computed_optional", e.NotComputedOptional)
+	d.Set("not_computed_required", e.NotComputedRequired)
+	d.Set("some_list", e.SomeList)
 
-		// In order to loop over the nested bar, we need to cast it to the appropriate type
-		for _, b := range f["bar"].([]any) {
-			log.Printf("\n\n>>> b: %+v (%T)\n\n", b, b)
+	// The backend only knows about the merged tags, so we have to work out
+	// which of them are the resource's own.
+	d.Set("tags", resourceTags(e.Tags, c.defaultTags, expandTags(d.Get("tags"))))
+	d.Set("tags_all", e.Tags)
 
-			// In order to update the 'version' field inside of 'bar', we need to
-			// cast bar to the appropriate type first
-			b := b.(map[string]any)
-			b["version"] = uuid.New().String()
-		}
+	// Nested blocks have to be converted into a []any of map[string]any before
+	// terraform will accept them (see flattenExampleFoo for the details).
+	if err := d.Set("foo", flattenExampleFoo(e.Foo)); err != nil {
+		return diag.FromErr(err)
 	}
-
-	log.Printf("\n\n>>> foo (AFTER UPDATE): %+v (%T)\n\n", foo, foo)
-
-	// Now we can set the updated data structure into local state.
-	if err := d.Set("foo", foo); err != nil {
-		return err
+	if err := d.Set("baz", flattenExampleBaz(e.Baz)); err != nil {
+		return diag.FromErr(err)
 	}
 
-	// I also make sure to update the computed 'last_update' attribute every time
-	// we update the terraform state.
-	d.Set("last_updated", time.Now().Format(time.RFC850))
+	// The computed 'last_updated' and 'revision' attributes are set by the
+	// backend every time the object changes.
+	d.Set("last_updated", e.LastUpdated)
+	d.Set("revision", e.Revision)
 
 	return nil
 }
 
-func resourceUpdate(d *schema.ResourceData, m any) error {
+func resourceUpdate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
 	log.Print("\n\n--- UPDATE ---\n\n")
 	log.Printf("\n\n>>> schema.ResourceData: %+v\n\n", d)
 	log.Printf("\n\n>>> meta data: %+v\n\n", m)
 
+	c := m.(*Client)
+
 	// We get the ID we set into terraform state after we had initially created
 	// the resource.
 	resourceID := d.Id()
 	log.Println("resourceID:", resourceID)
 
-	if d.HasChange("foo") {
-		foo := d.Get("foo").([]any)
-		log.Printf(">>> foo: %+v\n", foo)
-
-		// Imagine we made an API call to update the given resource.
-		//
-		// We'd do this by iterating over the foo we pulled out of our terraform
-		// state and coercing them into a type of map[string]any
-		//
-		// e.g.
-		//
-		// for _, f := range foo {
-		// 	i := f.(map[string]any)
-		//
-		// 	t := i["bar"].([]any)[0]
-		// 	bar := t.(map[string]any)
-		//
-		//  ...constructing data structure to pass to API...
-		//
-		//  We might assign values to the data structure like:
-		//
-		//  bar["id"].(int)).
-		// }
-
-		// TODO: update "version" to be 2
-
-		d.Set("last_updated", time.Now().Format(time.RFC850))
+	// The backend has a separate call for each part of the object, so we
+	// work out the new version of the whole object and then send only the
+	// parts that changed (see update_steps.go).
+	e := expandExample(d, c)
+	injectPlannedValueInconsistency(d, &e)
+	e.ID = resourceID
+
+	// We also tell the backend which revision of the object our changes are
+	// based on. The plan marks 'revision' as unknown (see the CustomizeDiff
+	// function) so we have to ask for the old value, which is the one from
+	// the last READ. Each call then builds on the revision the previous one
+	// produced.
+	revision, _ := d.GetChange("revision")
+
+	d.Partial(true)
+	calls := []string{}
+	for _, step := range exampleUpdateSteps(d, c, e) {
+		op, err := runUpdateStep(ctx, d, c, step, revision.(int))
+		if err != nil {
+			d.Set("backend_calls", calls)
+			return updateStepFailed(ctx, d, m, step.name, err)
+		}
+		revision = op.Example.Revision
+		calls = append(calls, step.request)
 	}
+	d.Set("backend_calls", calls)
+
+	// NOTE: Partial mode must be turned off again once everything worked,
+	// otherwise the SDK would save the previous state, as though nothing had
+	// changed.
+	d.Partial(false)
 
 	// Again, we do a READ operation to be sure we get the latest state stored locally.
 	//
-	return resourceRead(d, m)
+	return resourceRead(ctx, d, m)
 }
 
-func resourceDelete(d *schema.ResourceData, m any) error {
+func resourceDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
 	log.Print("\n\n--- DELETE ---\n\n")
 	log.Printf("\n\n>>> schema.ResourceData: %+v\n\n", d)
 	log.Printf("\n\n>>> meta data: %+v\n\n", m)
 
+	c := m.(*Client)
+
 	// We get the ID we set into terraform state after we had initially created
 	// the resource.
 	resourceID := d.Id()
 	log.Println(">>> resourceID:", resourceID)
 
-	// Imagine we use resourceID to issue a DELETE API call.
+	op, err := c.backend.deleteExample(ctx, resourceID)
+	if errors.Is(err, errNotFound) {
+		// It's already gone, which is what we wanted any way.
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := waitForOperation(ctx, c, op, d.Timeout(schema.TimeoutDelete)); err != nil {
+		return diag.FromErr(err)
+	}
 
 	// d.SetId("") is automatically called assuming delete returns no errors, but
 	// it is added here for explicitness.
@@ -321,3 +586,367 @@ func resourceDelete(d *schema.ResourceData, m any) error {
 
 	return nil
 }
+
+// resourceExampleCustomizeDiff calculates tags_all and marks the attributes
+// the backend changes on every update as 'known after apply' whenever there's
+// something to update. Without this the plan would claim they won't change,
+// and then they would.
+func resourceExampleCustomizeDiff(_ context.Context, d *schema.ResourceDiff, m any) error {
+	c := m.(*Client)
+
+	// Terraform only diffs the configuration against the state, so if the
+	// provider's default_tags change (and nothing else does) it wouldn't see
+	// anything to update. We work out what tags_all should be and set it in
+	// the plan ourselves, which also means the plan shows exactly which tags
+	// are going to change.
+	//
+	// NOTE: d.NewValueKnown misses a map that's unknown, as a whole or in
+	// part (d.Get then returns no tags, or only the known ones), so the raw
+	// configuration is checked as well. Otherwise the plan would promise
+	// the default tags alone, and the apply would break that promise.
+	config := d.GetRawConfig()
+	if !d.NewValueKnown("tags") || (!config.IsNull() && !config.GetAttr("tags").IsWhollyKnown()) {
+		// e.g. the tags reference an attribute of a resource that hasn't been
+		// created yet.
+		if err := d.SetNewComputed("tags_all"); err != nil {
+			return err
+		}
+	} else {
+		all := mergeTags(c.defaultTags, expandTags(d.Get("tags")))
+		if !tagsEqual(all, expandTags(d.Get("tags_all"))) {
+			if err := d.SetNew("tags_all", all); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Optional+Computed means removing not_computed_optional from the config
+	// doesn't show a diff, terraform assumes the value in state is one the
+	// backend chose. We know what the backend's default is, so if the value
+	// in state isn't it we plan for it to be assigned again.
+	//
+	// NOTE: This can only be done for top-level attributes (SetNewComputed
+	// doesn't work on the 'number' of a nested bar), and only because we
+	// know the default. Without it we couldn't tell a default from a value
+	// the user used to set, and would plan a change every time.
+	if config := d.GetRawConfig(); d.Id() != "" && !config.IsNull() && config.GetAttr("not_computed_optional").IsNull() {
+		if d.Get("not_computed_optional").(string) != defaultNotComputedOptional {
+			if err := d.SetNewComputed("not_computed_optional"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Entries of foo are identified by their key (see foo_diff.go), so no two
+	// can have the same one.
+	keys := map[string]bool{}
+	for _, f := range d.Get("foo").([]any) {
+		f, _ := f.(map[string]any)
+		key, _ := f["key"].(string)
+		if key == "" {
+			continue
+		}
+		if keys[key] {
+			return fmt.Errorf("foo: key %q is used by more than one entry", key)
+		}
+		keys[key] = true
+	}
+
+	if d.Id() == "" || len(d.GetChangedKeysPrefix("")) == 0 {
+		return nil
+	}
+	if err := d.SetNewComputed("revision"); err != nil {
+		return err
+	}
+	if err := d.SetNewComputed("backend_calls"); err != nil {
+		return err
+	}
+	return d.SetNewComputed("last_updated")
+}
+
+// expandExample converts the terraform configuration into the data structure
+// the backend expects.
+//
+// Nested blocks come out of d.Get as a []any where each element is a
+// map[string]any, so we have to type assert our way down to the values.
+func expandExample(d *schema.ResourceData, c *Client) example {
+	e := example{
+		Name:                d.Get("name").(string),
+		NamePrefix:          d.Get("name_prefix").(string),
+		NotComputedOptional: d.Get("not_computed_optional").(string),
+		NotComputedRequired: d.Get("not_computed_required").(string),
+	}
+
+	// d.Get still returns the old value when not_computed_optional is being
+	// reset, so we leave it empty for the backend to fill in.
+	if resettingNotComputedOptional(d) {
+		e.NotComputedOptional = ""
+	}
+
+	e.Foo = expandExampleFoo(d.Get("foo"))
+	omitUnknownBarNumbers(e.Foo, d.GetRawPlan())
+
+	for _, b := range d.Get("baz").(*schema.Set).List() {
+		b := b.(map[string]any)
+		e.Baz = append(e.Baz, exampleBaz{
+			Qux: b["qux"].(string),
+		})
+	}
+
+	for _, v := range d.Get("some_list").([]any) {
+		e.SomeList = append(e.SomeList, v.(string))
+	}
+
+	// The backend is sent all the tags, i.e. tags_all. That attribute is
+	// computed by the CustomizeDiff function, but we work it out again here
+	// from 'tags' rather than trusting the plan, because tags_all is unknown
+	// in the plan when 'tags' weren't known until apply time.
+	e.Tags = mergeTags(c.defaultTags, expandTags(d.Get("tags")))
+
+	return e
+}
+
+// expandExampleFoo converts foo from what d.Get returns into the backend's
+// representation.
+func expandExampleFoo(v any) []exampleFoo {
+	var result []exampleFoo
+	for _, f := range v.([]any) {
+		f := f.(map[string]any)
+
+		foo := exampleFoo{
+			Key: f["key"].(string),
+		}
+		for _, b := range f["bar"].([]any) {
+			// An empty 'bar {}' block comes through as nil rather than an empty
+			// map, so we use the two value form of the type assertions to
+			// avoid a panic and fall back to the zero value.
+			b, _ := b.(map[string]any)
+			number, _ := b["number"].(int)
+			foo.Bar = append(foo.Bar, exampleBar{
+				Number: &number,
+			})
+		}
+		result = append(result, foo)
+	}
+	return result
+}
+
+// resettingNotComputedOptional reports whether the CustomizeDiff function
+// planned for not_computed_optional to be assigned its default again, i.e.
+// whether it's unknown in the plan.
+//
+// NOTE: d.HasChange doesn't report this as a change, as the SDK only compares
+// the old value with the new one, and the new one isn't known yet.
+func resettingNotComputedOptional(d *schema.ResourceData) bool {
+	plan := d.GetRawPlan()
+	return !plan.IsNull() && plan.IsKnown() && !plan.GetAttr("not_computed_optional").IsKnown()
+}
+
+// omitUnknownBarNumbers clears the number of every bar that the plan says is
+// unknown, so the backend assigns its default.
+//
+// NOTE: We can't tell from d.Get, which returns 0 for a number that isn't
+// known yet, exactly as it does for a number set to 0. The raw plan knows
+// the difference. It's only unknown when the number was omitted from a bar
+// that didn't exist before: for an existing bar, terraform plans to keep the
+// number it already had.
+func omitUnknownBarNumbers(foo []exampleFoo, plan cty.Value) {
+	if plan.IsNull() || !plan.IsKnown() {
+		return
+	}
+	planFoo := plan.GetAttr("foo")
+	if planFoo.IsNull() || !planFoo.IsKnown() {
+		return
+	}
+	for i, f := range planFoo.AsValueSlice() {
+		if i >= len(foo) || f.IsNull() || !f.GetAttr("bar").IsKnown() || f.GetAttr("bar").IsNull() {
+			continue
+		}
+		for j, b := range f.GetAttr("bar").AsValueSlice() {
+			if j < len(foo[i].Bar) && !b.GetAttr("number").IsKnown() {
+				foo[i].Bar[j].Number = nil
+			}
+		}
+	}
+}
+
+// flattenExampleFoo converts foo from the backend's representation into one
+// that d.Set understands.
+func flattenExampleFoo(foo []exampleFoo) []any {
+	result := make([]any, 0, len(foo))
+	for _, f := range foo {
+		bar := make([]any, 0, len(f.Bar))
+		for _, b := range f.Bar {
+			m := map[string]any{
+				"version": b.Version,
+			}
+			// The backend always assigns a number, but state from before
+			// it did might not have one.
+			if b.Number != nil {
+				m["number"] = *b.Number
+			}
+			bar = append(bar, m)
+		}
+		result = append(result, map[string]any{
+			"key": f.Key,
+			"bar": bar,
+		})
+	}
+	return result
+}
+
+// hashExampleBaz is the Set function for baz. The hash identifies an element
+// of the set, so it should only include the attributes that make an element
+// unique. Two elements with the same hash are treated as the same element
+// (i.e. duplicates are dropped).
+func hashExampleBaz(v any) int {
+	m := v.(map[string]any)
+	return schema.HashString(m["qux"])
+}
+
+// resourceExampleV0 is the schema of mock_example as it was at version 0.
+//
+// NOTE:
+// This is a frozen copy, and must never be changed (or derived from the
+// current schema), because it describes state that was written in the past.
+// Attributes added since version 0, such as backend_calls and
+// inconsistency_mode, don't belong here: an upgrader is given the state as it
+// was written, and anything it doesn't return is dropped by the SDK if it's
+// no longer in the current schema.
+func resourceExampleV0() *schema.Resource {
+	return &schema.Resource{
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"name_prefix"},
+			},
+			"name_prefix": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"name"},
+			},
+			"last_updated": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"revision": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"not_computed_optional": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"not_computed_required": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"foo": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"bar": {
+							Type:     schema.TypeList,
+							MaxItems: 1,
+							Required: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"number": {
+										Type:     schema.TypeInt,
+										Optional: true,
+									},
+									"version": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			// The only difference that needs upgrading: baz was a list.
+			"baz": {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"qux": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+			"some_list": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"tags": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"tags_all": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// resourceExampleStateUpgradeV0 migrates state from version 0 to version 1.
+//
+// The JSON representation of a list of blocks and a set of blocks is the same
+// (an array of objects), so there isn't much to do. The one difference is that
+// a set can't contain duplicates, and a list could, so we drop them.
+func resourceExampleStateUpgradeV0(_ context.Context, rawState map[string]any, _ any) (map[string]any, error) {
+	log.Printf(">>> upgrading mock_example state from version 0: %+v", rawState)
+
+	baz, _ := rawState["baz"].([]any)
+
+	seen := make(map[int]bool)
+	upgraded := make([]any, 0, len(baz))
+	for _, b := range baz {
+		h := hashExampleBaz(b)
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		upgraded = append(upgraded, b)
+	}
+	rawState["baz"] = upgraded
+
+	return rawState, nil
+}
+
+// flattenExampleBaz converts baz from the backend's representation into one
+// that d.Set understands.
+func flattenExampleBaz(baz []exampleBaz) []any {
+	result := make([]any, 0, len(baz))
+	for _, b := range baz {
+		result = append(result, map[string]any{
+			"qux": b.Qux,
+		})
+	}
+	return result
+}
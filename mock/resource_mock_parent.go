@@ -0,0 +1,116 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceParent is the 'one' side of a one-to-many relationship with
+// mock_child. The backend refuses to delete a parent that still has children,
+// see backend_parent.go for the details.
+func resourceParent() *schema.Resource {
+	return &schema.Resource{
+		Description: "A parent object that mock_child resources belong to.",
+
+		CreateContext: resourceParentCreate,
+		ReadContext:   resourceParentRead,
+		UpdateContext: resourceParentUpdate,
+		DeleteContext: resourceParentDelete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the parent.",
+			},
+		},
+	}
+}
+
+func resourceParentCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	p := parent{
+		ID:   uuid.New().String(),
+		Name: d.Get("name").(string),
+	}
+	err := c.backend.createParent(ctx, p)
+	if errors.Is(err, errAlreadyExists) {
+		return alreadyExistsDiagnostic("mock_parent", err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(p.ID)
+
+	return resourceParentRead(ctx, d, m)
+}
+
+func resourceParentRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	p, err := c.backend.getParent(ctx, d.Id())
+	if errors.Is(err, errNotFound) {
+		log.Printf(">>> parent %q not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.Set("name", p.Name)
+
+	return nil
+}
+
+func resourceParentUpdate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	err := c.backend.updateParent(ctx, parent{
+		ID:   d.Id(),
+		Name: d.Get("name").(string),
+	})
+	if errors.Is(err, errAlreadyExists) {
+		return nameTakenDiagnostic("mock_parent", err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceParentRead(ctx, d, m)
+}
+
+func resourceParentDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	err := c.backend.deleteParent(ctx, d.Id())
+	if errors.Is(err, errConflict) {
+		// This is the error users see when terraform destroys things in the
+		// wrong order, which only happens when it doesn't know about the
+		// dependency, so we point them at the likely cause.
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Cannot delete parent with children",
+			Detail: err.Error() + "\n\nTerraform destroys resources in the reverse order of their " +
+				"dependencies. If the children are managed by terraform, make sure they reference " +
+				"this parent (e.g. `parent_id = mock_parent.example.id`) rather than a hardcoded ID, " +
+				"or add an explicit `depends_on`.",
+		}}
+	}
+	if err != nil && !errors.Is(err, errNotFound) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
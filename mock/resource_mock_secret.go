@@ -0,0 +1,178 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceSecret demonstrates how to handle sensitive data.
+//
+// There are a few separate ideas at play here:
+//
+//  1. `Sensitive: true` only hides a value from the plan output (it is shown
+//     as `(sensitive value)`). It does NOT keep the value out of the state
+//     file, which is plain JSON.
+//
+//  2. To keep the user's secret out of state entirely we give the attribute a
+//     StateFunc, which transforms the value before it is written to state. We
+//     store a hash of the value, which is still enough for terraform to
+//     notice when the user changes it in their configuration (because the
+//     configured value is hashed with the same StateFunc when diffing).
+//
+//  3. The backend never returns the value once written (it's write-only),
+//     only its hash. That lets READ detect a value changed outside of
+//     terraform without ever holding the real value.
+//
+//  4. The API key is generated by the backend, so it has to live in state for
+//     it to be useful to other resources. Marking it Sensitive hides it from
+//     plan output and from `terraform output` unless explicitly asked for.
+//
+// NOTE:
+// Be careful what you log! The other resources in this provider dump the
+// whole *schema.ResourceData for teaching purposes, but doing that here would
+// write the plaintext secret into the TF_LOG output.
+func resourceSecret() *schema.Resource {
+	return &schema.Resource{
+		Description: "A write-only secret value with a server-generated API key.",
+
+		CreateContext: resourceSecretCreate,
+		ReadContext:   resourceSecretRead,
+		UpdateContext: resourceSecretUpdate,
+		DeleteContext: resourceSecretDelete,
+
+		// NOTE:
+		// Importing only needs the ID. READ sets 'value' to the hash the
+		// backend returns, which is what the StateFunc turns the configured
+		// value into, so an imported secret has no diff unless its value
+		// really is different.
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		CustomizeDiff: resourceSecretCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The name of the secret.",
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Sensitive:   true,
+				Description: "The secret value. Only a SHA-256 hash of the value is stored in state.",
+				StateFunc: func(v any) string {
+					return hashSecretValue(v.(string))
+				},
+			},
+			"rotation_trigger": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "An arbitrary value that, when changed, causes the `api_key` to be rotated.",
+			},
+			"api_key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "An API key generated by the backend.",
+			},
+		},
+	}
+}
+
+// resourceSecretCustomizeDiff makes the plan show that the API key will
+// change when a rotation is triggered. Without this the plan would only show
+// the change to rotation_trigger and the new key would appear 'by surprise'
+// after the apply (which terraform reports as an inconsistent result).
+func resourceSecretCustomizeDiff(_ context.Context, d *schema.ResourceDiff, _ any) error {
+	if d.Id() != "" && d.HasChange("rotation_trigger") {
+		return d.SetNewComputed("api_key")
+	}
+	return nil
+}
+
+func resourceSecretCreate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	// NOTE:
+	// d.Get returns the value as the user configured it, not the output of
+	// the StateFunc, so this is the plaintext secret.
+	sec, err := c.backend.createSecret(ctx, secret{
+		ID:    uuid.New().String(),
+		Name:  d.Get("name").(string),
+		Value: d.Get("value").(string),
+	})
+	if errors.Is(err, errAlreadyExists) {
+		return alreadyExistsDiagnostic("mock_secret", err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(sec.ID)
+	log.Printf(">>> created secret %q", sec.ID)
+
+	return resourceSecretRead(ctx, d, m)
+}
+
+func resourceSecretRead(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	sec, err := c.backend.getSecret(ctx, d.Id())
+	if errors.Is(err, errNotFound) {
+		log.Printf(">>> secret %q not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.Set("name", sec.Name)
+	d.Set("api_key", sec.APIKey)
+
+	// The backend only gives us the hash, which is exactly what the StateFunc
+	// stores, so if the secret was changed outside of terraform the next plan
+	// will show a diff for 'value'.
+	d.Set("value", sec.ValueHash)
+
+	return nil
+}
+
+func resourceSecretUpdate(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	if d.HasChange("value") {
+		if err := c.backend.setSecretValue(ctx, d.Id(), d.Get("value").(string)); err != nil {
+			return diag.FromErr(err)
+		}
+		log.Printf(">>> updated value of secret %q", d.Id())
+	}
+
+	if d.HasChange("rotation_trigger") {
+		if _, err := c.backend.rotateSecretAPIKey(ctx, d.Id()); err != nil {
+			return diag.FromErr(err)
+		}
+		log.Printf(">>> rotated api_key of secret %q", d.Id())
+	}
+
+	return resourceSecretRead(ctx, d, m)
+}
+
+func resourceSecretDelete(ctx context.Context, d *schema.ResourceData, m any) diag.Diagnostics {
+	c := m.(*Client)
+
+	err := c.backend.deleteSecret(ctx, d.Id())
+	if err != nil && !errors.Is(err, errNotFound) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
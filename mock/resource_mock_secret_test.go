@@ -0,0 +1,57 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestResourceSecret(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	config := map[string]any{"name": "secret", "value": "hunter2", "rotation_trigger": "1"}
+	state := p.apply("mock_secret", cty.NilVal, config)
+
+	// Only the hash of the value is in state, which is what the backend gives
+	// back, so there's nothing to change until the value is.
+	if got, want := state.GetAttr("value"), cty.StringVal(hashSecretValue("hunter2")); !got.RawEquals(want) {
+		t.Fatalf("expected the value to be stored as its hash %#v, got %#v", want, got)
+	}
+	apiKey := state.GetAttr("api_key")
+	if !apiKey.IsKnown() || apiKey.IsNull() || apiKey.AsString() == "" {
+		t.Fatalf("expected the backend to have generated an api_key, got %#v", apiKey)
+	}
+	p.planEmpty("mock_secret", state, config)
+
+	// A new rotation_trigger shows the api_key as unknown in the plan, and
+	// the apply rotates it.
+	config["rotation_trigger"] = "2"
+	if planned := p.plan("mock_secret", state, config); planned.GetAttr("api_key").IsKnown() {
+		t.Fatalf("expected the api_key to be unknown in the plan, got %#v", planned.GetAttr("api_key"))
+	}
+	state = p.apply("mock_secret", state, config)
+	if rotated := state.GetAttr("api_key"); rotated.RawEquals(apiKey) || rotated.AsString() == "" {
+		t.Fatalf("expected the api_key to have been rotated, got %#v", rotated)
+	}
+	if got, want := state.GetAttr("value"), cty.StringVal(hashSecretValue("hunter2")); !got.RawEquals(want) {
+		t.Fatalf("expected rotating the api_key to leave the value alone, got %#v", got)
+	}
+	p.planEmpty("mock_secret", state, config)
+
+	// A value changed outside of terraform shows up as a different hash.
+	if err := p.client().backend.setSecretValue(context.Background(), state.GetAttr("id").AsString(), "changed"); err != nil {
+		t.Fatal(err)
+	}
+	state = p.read("mock_secret", state)
+	if got, want := state.GetAttr("value"), cty.StringVal(hashSecretValue("changed")); !got.RawEquals(want) {
+		t.Fatalf("expected read to find the hash of the new value %#v, got %#v", want, got)
+	}
+	if planned := p.plan("mock_secret", state, config); !planned.GetAttr("value").RawEquals(cty.StringVal(hashSecretValue("hunter2"))) {
+		t.Fatalf("expected the plan to put the configured value back, got %#v", planned.GetAttr("value"))
+	}
+	state = p.apply("mock_secret", state, config)
+
+	p.destroy("mock_secret", state)
+}
@@ -0,0 +1,61 @@
+package mock
+
+// Tags are modelled on the AWS provider:
+//
+//   - the provider has a 'default_tags' block with tags to apply to every
+//     resource.
+//   - each resource has a 'tags' argument for its own tags.
+//   - each resource has a computed 'tags_all' attribute with the two merged
+//     together (the resource's own tags win when the same key appears in
+//     both), which is what is actually sent to the backend.
+
+// expandTags converts a TypeMap value into a map of strings.
+func expandTags(v any) map[string]string {
+	tags := make(map[string]string)
+	m, _ := v.(map[string]any)
+	for k, v := range m {
+		tags[k], _ = v.(string)
+	}
+	return tags
+}
+
+// mergeTags returns the default tags overlaid with the resource's tags.
+func mergeTags(defaults, tags map[string]string) map[string]string {
+	all := make(map[string]string, len(defaults)+len(tags))
+	for k, v := range defaults {
+		all[k] = v
+	}
+	for k, v := range tags {
+		all[k] = v
+	}
+	return all
+}
+
+// resourceTags works out the resource's own tags from all the tags the
+// backend has for it. A tag is the resource's own if it isn't one of the
+// defaults, if it has a different value to the default, or if the resource
+// already had it (i.e. it was configured with the same value as the default).
+func resourceTags(all, defaults, previous map[string]string) map[string]string {
+	tags := make(map[string]string)
+	for k, v := range all {
+		dv, isDefault := defaults[k]
+		_, hadTag := previous[k]
+		if !isDefault || dv != v || hadTag {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// tagsEqual reports whether a and b contain the same tags.
+func tagsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+// testDefaultTags returns the default_tags block of a provider configuration.
+func testDefaultTags(tags map[string]string) map[string]any {
+	return map[string]any{"default_tags": []map[string]any{{"tags": tags}}}
+}
+
+// testCheckTags checks the tags and tags_all of a mock_example.
+func testCheckTags(t *testing.T, state cty.Value, tags, tagsAll map[string]string) {
+	t.Helper()
+	for attr, want := range map[string]map[string]string{"tags": tags, "tags_all": tagsAll} {
+		got := map[string]string{}
+		if v := state.GetAttr(attr); !v.IsNull() {
+			for k, v := range v.AsValueMap() {
+				got[k] = v.AsString()
+			}
+		}
+		if !tagsEqual(got, want) {
+			t.Errorf("expected %s to be %v, got %v", attr, want, got)
+		}
+	}
+}
+
+func TestResourceExample_defaultTags(t *testing.T) {
+	testProviderEnv(t)
+	config := testExampleConfig(map[string]any{"tags": map[string]string{"team": "app"}})
+
+	// The resource's own tags win over the provider's, and tags_all is what
+	// the backend gets.
+	p := newTestGRPCProvider(t, testDefaultTags(map[string]string{"env": "dev", "team": "platform"}))
+	state := p.apply("mock_example", cty.NilVal, config)
+	testCheckTags(t, state, map[string]string{"team": "app"}, map[string]string{"env": "dev", "team": "app"})
+	stored, err := p.client().backend.getExample(context.Background(), state.GetAttr("id").AsString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tagsEqual(stored.Tags, map[string]string{"env": "dev", "team": "app"}) {
+		t.Errorf("expected the backend to have every tag, got %v", stored.Tags)
+	}
+
+	// Reading it back doesn't turn the default tags into the resource's own.
+	state = p.read("mock_example", state)
+	testCheckTags(t, state, map[string]string{"team": "app"}, map[string]string{"env": "dev", "team": "app"})
+	p.planEmpty("mock_example", state, config)
+
+	// Changing only the default tags shows up in tags_all in the plan.
+	p = newTestGRPCProvider(t, testDefaultTags(map[string]string{"env": "prod", "team": "platform"}))
+	planned := p.plan("mock_example", state, config)
+	testCheckTags(t, planned, map[string]string{"team": "app"}, map[string]string{"env": "prod", "team": "app"})
+	state = p.apply("mock_example", state, config)
+	state = p.read("mock_example", state)
+	testCheckTags(t, state, map[string]string{"team": "app"}, map[string]string{"env": "prod", "team": "app"})
+	p.planEmpty("mock_example", state, config)
+
+	// A tag of the resource's own that has the same value as a default one
+	// stays the resource's own.
+	config["tags"] = map[string]string{"team": "app", "env": "prod"}
+	state = p.apply("mock_example", state, config)
+	state = p.read("mock_example", state)
+	testCheckTags(t, state, map[string]string{"team": "app", "env": "prod"}, map[string]string{"env": "prod", "team": "app"})
+	p.planEmpty("mock_example", state, config)
+
+	// Tags that aren't known until apply make tags_all unknown too, whether
+	// it's the whole map or one of its values, for a new object or not.
+	for _, tags := range []cty.Value{
+		cty.UnknownVal(cty.Map(cty.String)),
+		cty.MapVal(map[string]cty.Value{"team": cty.UnknownVal(cty.String)}),
+	} {
+		config["tags"] = tags
+		for _, prior := range []cty.Value{cty.NilVal, state} {
+			if planned := p.plan("mock_example", prior, config); planned.GetAttr("tags_all").IsKnown() {
+				t.Errorf("expected tags %#v to make tags_all unknown, got %#v", tags, planned.GetAttr("tags_all"))
+			}
+		}
+	}
+}
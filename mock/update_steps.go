@@ -0,0 +1,162 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Updating a mock_example is made up of one backend call for each part of the
+// object that changed (see patchExample): its top-level fields, its foo
+// entries, its baz settings and its some_list items. Any of them can fail,
+// by which point the earlier ones have already been applied.
+//
+// Terraform has to end up with a state that reflects what was actually
+// applied, which is where d.Partial comes in. If UPDATE returns an error, the
+// SDK saves the *planned* values to state by default, as though every change
+// had been made. With d.Partial(true), it saves the previous state instead.
+//
+// Neither is right after a partial failure, so once a call fails we READ the
+// object back and turn partial mode off again, and the state ends up with
+// exactly the changes that were applied. Partial mode is only left on if the
+// READ fails too, in which case the previous state is the safest thing to
+// keep: the next plan will simply try the changes again.
+//
+// The 'fail_update_step' attribute makes one of the calls fail on purpose.
+const (
+	updateStepFields   = "fields"
+	updateStepFoo      = "foo"
+	updateStepBaz      = "baz"
+	updateStepSomeList = "some_list"
+)
+
+// exampleUpdateSteps returns the backend calls needed to apply the changes in
+// d, where e is the new version of the object.
+func exampleUpdateSteps(d *schema.ResourceData, c *Client, e example) []exampleStep {
+	var steps []exampleStep
+
+	// tags_all is what's sent to the backend, and it changes when either the
+	// resource's tags or the provider's default_tags change.
+	if d.HasChanges("not_computed_optional", "not_computed_required", "tags_all") || resettingNotComputedOptional(d) {
+		steps = append(steps, exampleStep{
+			name: updateStepFields,
+			call: func(ctx context.Context, revision int) (*operation, error) {
+				return c.backend.setExampleFields(ctx, e.ID, revision, e)
+			},
+			request: "PUT fields",
+		})
+	}
+	if d.HasChange("foo") {
+		// Rather than replace the whole list, we tell the backend about the
+		// entries that changed (see foo_diff.go), unless they were reordered.
+		//
+		// NOTE: The patch is worked out from the foo entries in state. If
+		// someone else has added or removed entries since, it would change
+		// the wrong ones, which is one more reason for the revision check
+		// (and for being careful with conflict_policy = "refresh_and_retry").
+		old, _ := d.GetChange("foo")
+		ops, ok := diffExampleFoo(expandExampleFoo(old), e.Foo)
+		switch {
+		case !ok:
+			steps = append(steps, exampleStep{
+				name: updateStepFoo,
+				call: func(ctx context.Context, revision int) (*operation, error) {
+					return c.backend.setExampleFoo(ctx, e.ID, revision, e.Foo)
+				},
+				request: "PUT foo",
+			})
+		case len(ops) > 0:
+			request := "PATCH foo:"
+			for i, op := range ops {
+				if i > 0 {
+					request += ","
+				}
+				request += " " + op.String()
+			}
+			steps = append(steps, exampleStep{
+				name: updateStepFoo,
+				call: func(ctx context.Context, revision int) (*operation, error) {
+					return c.backend.patchExampleFoo(ctx, e.ID, revision, ops)
+				},
+				request: request,
+			})
+		}
+	}
+	if d.HasChange("baz") {
+		steps = append(steps, exampleStep{
+			name: updateStepBaz,
+			call: func(ctx context.Context, revision int) (*operation, error) {
+				return c.backend.setExampleBaz(ctx, e.ID, revision, e.Baz)
+			},
+			request: "PUT baz",
+		})
+	}
+	if d.HasChange("some_list") {
+		steps = append(steps, exampleStep{
+			name: updateStepSomeList,
+			call: func(ctx context.Context, revision int) (*operation, error) {
+				return c.backend.setExampleSomeList(ctx, e.ID, revision, e.SomeList)
+			},
+			request: "PUT some_list",
+		})
+	}
+
+	return steps
+}
+
+// runUpdateStep runs the step, and if it's rejected because the object has
+// changed since it was last read, retries it against the latest revision
+// (when the 'refresh_and_retry' conflict policy allows it).
+func runUpdateStep(ctx context.Context, d *schema.ResourceData, c *Client, step exampleStep, revision int) (*operation, error) {
+	for attempt := 1; ; attempt++ {
+		op, err := runExampleStep(ctx, d, c, step, revision, "fail_update_step", d.Timeout(schema.TimeoutUpdate))
+		if !errors.Is(err, errConflict) || c.conflictPolicy != conflictPolicyRefreshAndRetry || attempt >= maxConflictRetries {
+			return op, err
+		}
+
+		latest, err := c.backend.getExample(ctx, d.Id())
+		if err != nil {
+			return nil, err
+		}
+		log.Printf(">>> conflict updating %q (attempt %d), retrying against revision %d", d.Id(), attempt, latest.Revision)
+		revision = latest.Revision
+	}
+}
+
+// updateStepFailed records the changes that were applied before the step
+// failed (see above) and reports the failure.
+func updateStepFailed(ctx context.Context, d *schema.ResourceData, m any, step string, stepErr error) diag.Diagnostics {
+	diags := resourceRead(ctx, d, m)
+	if !diags.HasError() {
+		d.Partial(false)
+	}
+
+	if errors.Is(stepErr, errConflict) {
+		// Someone else changed the object between our last READ and now.
+		//
+		// By default we stop and tell the user, because blindly applying our
+		// changes would throw away theirs without anyone having reviewed it
+		// in a plan. The alternative policy is to fetch the latest revision
+		// and try again, which is 'last write wins' and only safe when
+		// terraform is meant to be the sole owner of the object.
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Object was modified outside of this terraform run",
+			Detail: stepErr.Error() + "\n\nAnother terraform run (or something outside of terraform) " +
+				"changed this object after it was last read. Run `terraform plan` again to review " +
+				"the latest changes before applying, or set `conflict_policy = \"" +
+				conflictPolicyRefreshAndRetry + "\"` in the provider configuration to overwrite them.",
+		})
+	}
+
+	return append(diags, diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("Failed to update example: the %s step failed", step),
+		Detail: stepErr.Error() + "\n\nAny changes made before this step were applied and saved to " +
+			"state, the rest will show up in the next plan.",
+	})
+}
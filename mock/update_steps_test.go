@@ -0,0 +1,68 @@
+package mock
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestUpdateStepFailed(t *testing.T) {
+	testProviderEnv(t)
+	p := newTestGRPCProvider(t, nil)
+
+	state := p.apply("mock_example", cty.NilVal, testExampleConfig(map[string]any{
+		"not_computed_required": "r1",
+		"some_list":             []string{"a"},
+	}))
+
+	// The fields step runs before the baz step, which fails, so some_list
+	// isn't even attempted.
+	config := testExampleConfig(map[string]any{
+		"not_computed_required": "r2",
+		"baz":                   []map[string]any{{"qux": "y"}},
+		"some_list":             []string{"b"},
+		"fail_update_step":      updateStepBaz,
+	})
+	state, diags := p.tryApply("mock_example", state, config)
+	testExpectError(t, diags, "^Failed to update example: the baz step failed$", `^simulated failure \(fail_update_step = "baz"\)(?s).*saved to state`)
+
+	stored, err := p.client().backend.getExample(context.Background(), state.GetAttr("id").AsString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.NotComputedRequired != "r2" || !reflect.DeepEqual(stored.Baz, []exampleBaz{{Qux: "x"}}) || !reflect.DeepEqual(stored.SomeList, []string{"a"}) {
+		t.Errorf("expected the backend to have only the new fields, got %+v", stored)
+	}
+
+	// The state has what was applied, rather than the previous state or the
+	// plan, apart from baz, which the SDK returns as planned like every set
+	// after an apply, until the next refresh.
+	if got := state.GetAttr("not_computed_required"); !got.RawEquals(cty.StringVal("r2")) {
+		t.Errorf("expected not_computed_required to be r2 in state, got %#v", got)
+	}
+	if got, want := state.GetAttr("some_list"), cty.ListVal([]cty.Value{cty.StringVal("a")}); !got.RawEquals(want) {
+		t.Errorf("expected some_list to be %#v in state, got %#v", want, got)
+	}
+	state = p.read("mock_example", state)
+	if got, want := state.GetAttr("baz"), cty.SetVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{"qux": cty.StringVal("x")})}); !got.RawEquals(want) {
+		t.Errorf("expected baz to be %#v after a refresh, got %#v", want, got)
+	}
+
+	// So the next plan only has the changes that weren't made (besides the
+	// computed attributes that any update recomputes).
+	delete(config, "fail_update_step")
+	planned := p.plan("mock_example", state, config)
+	for name := range state.Type().AttributeTypes() {
+		if !planned.GetAttr(name).IsKnown() {
+			continue
+		}
+		changed := !planned.GetAttr(name).RawEquals(state.GetAttr(name))
+		if want := name == "baz" || name == "some_list" || name == "fail_update_step"; changed != want {
+			t.Errorf("expected %s to change in the next plan: %v, got %#v -> %#v", name, want, state.GetAttr(name), planned.GetAttr(name))
+		}
+	}
+	state = p.apply("mock_example", state, config)
+	p.planEmpty("mock_example", state, config)
+}
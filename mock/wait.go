@@ -0,0 +1,75 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+// waitForOperation polls the backend until the given operation has finished,
+// returning an error if it failed or didn't finish within timeout.
+//
+// The SDK provides resource.StateChangeConf for exactly this job. It calls
+// Refresh repeatedly, backing off exponentially between calls (starting at
+// MinTimeout and capped at 10 seconds), until the returned state is one of
+// the Target states. Any state that's neither Pending nor Target is treated
+// as an error, as is running out of time or the context being cancelled
+// (e.g. the user pressing Ctrl-C).
+func waitForOperation(ctx context.Context, c *Client, op *operation, timeout time.Duration) error {
+	if op.done() {
+		return operationError(op)
+	}
+
+	conf := &resource.StateChangeConf{
+		Pending:    []string{operationPending, operationRunning},
+		Target:     []string{operationDone},
+		Timeout:    timeout,
+		MinTimeout: 500 * time.Millisecond,
+		Refresh: func() (any, string, error) {
+			latest, err := c.backend.getOperation(ctx, op.ID)
+			if err != nil {
+				return nil, "", err
+			}
+			log.Printf(">>> operation %s (%s of %s): %s", latest.ID, latest.Kind, latest.Target, latest.Status)
+			return latest, latest.Status, operationError(latest)
+		},
+	}
+
+	_, err := conf.WaitForStateContext(ctx)
+
+	// NOTE: The SDK gives the CRUD function a context with the same timeout,
+	// and that deadline usually passes first, in which case the error is the
+	// context's rather than a *resource.TimeoutError.
+	var timeoutErr *resource.TimeoutError
+	if errors.As(err, &timeoutErr) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s waiting for %s of %s (operation %s) to finish: the change may still complete in the background", timeout, op.Kind, op.Target, op.ID)
+	}
+	return err
+}
+
+// operationError returns the error of a FAILED operation, and nil otherwise.
+func operationError(op *operation) error {
+	if op.Status != operationFailed {
+		return nil
+	}
+	return &operationFailedError{op: op}
+}
+
+// operationFailedError is the error for a FAILED operation. It unwraps to
+// the backend error the operation failed with (if any) so that callers can
+// still use errors.Is(err, errConflict) and friends.
+type operationFailedError struct {
+	op *operation
+}
+
+func (e *operationFailedError) Error() string {
+	return fmt.Sprintf("operation %s failed: %s", e.op.ID, e.op.Error)
+}
+
+func (e *operationFailedError) Unwrap() error {
+	return backendErrorCodes[e.op.ErrorCode]
+}